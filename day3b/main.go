@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"math"
+	"math/big"
 	"os"
 	"strings"
 )
@@ -50,6 +51,124 @@ func getBankMax(bank string, digUsed int) int {
 	return finalDigit
 }
 
+type Objective int
+
+const (
+	Largest Objective = iota
+	Smallest
+)
+
+// zero values of maxGap and window mean "no constraint"
+type Mode struct {
+	objective      Objective
+	maxGap         int
+	window         int
+	distinctDigits bool
+}
+
+type selectionState struct {
+	last      int
+	remaining int
+	usedMask  int
+	limit     int
+}
+
+type selection struct {
+	digits string
+	ok     bool
+}
+
+func isBetter(candidate, current string, objective Objective) bool {
+	if objective == Smallest {
+		return candidate < current
+	}
+	return candidate > current
+}
+
+func selectDigits(ints []int, state selectionState, mode Mode, cache map[selectionState]selection) selection {
+	if state.remaining == 0 {
+		return selection{digits: "", ok: true}
+	}
+	if cached, ok := cache[state]; ok {
+		return cached
+	}
+
+	endPos := min(len(ints)-state.remaining, state.limit)
+	if mode.maxGap > 0 && state.last >= 0 {
+		endPos = min(endPos, state.last+mode.maxGap)
+	}
+
+	best := selection{}
+	for pos := state.last + 1; pos <= endPos; pos++ {
+		digitBit := 1 << ints[pos]
+		if mode.distinctDigits && state.usedMask&digitBit != 0 {
+			continue
+		}
+
+		// usedMask and limit only join the memo key when a constraint needs them,
+		// so the unconstrained modes keep O(n*k) states
+		usedMask := state.usedMask
+		if mode.distinctDigits {
+			usedMask |= digitBit
+		}
+		limit := state.limit
+		if state.last < 0 && mode.window > 0 {
+			limit = min(limit, pos+mode.window-1)
+		}
+
+		next := selectDigits(ints, selectionState{
+			last:      pos,
+			remaining: state.remaining - 1,
+			usedMask:  usedMask,
+			limit:     limit,
+		}, mode, cache)
+		if !next.ok {
+			continue
+		}
+
+		candidate := string(rune('0'+ints[pos])) + next.digits
+		if !best.ok || isBetter(candidate, best.digits, mode.objective) {
+			best = selection{digits: candidate, ok: true}
+		}
+	}
+
+	cache[state] = best
+	return best
+}
+
+func getBankBest(bank string, digUsed int, mode Mode) int {
+	ints := []int{}
+	for _, ch := range bank {
+		ints = append(ints, int(ch-'0'))
+	}
+
+	best := selectDigits(ints, selectionState{
+		last:      -1,
+		remaining: digUsed,
+		usedMask:  0,
+		limit:     len(ints) - 1,
+	}, mode, map[selectionState]selection{})
+	if !best.ok {
+		panic("No valid selection found")
+	}
+
+	finalDigit := 0
+	for _, ch := range best.digits {
+		finalDigit = finalDigit*10 + int(ch-'0')
+	}
+
+	return finalDigit
+}
+
+func runMode(banks []string, digUsed int, mode Mode) int {
+	total := 0
+	for _, bank := range banks {
+		bankBest := getBankBest(bank, digUsed, mode)
+		total += bankBest
+	}
+	return total
+}
+
 func run(banks []string, digUsed int) int {
 	total := 0
 	for _, bank := range banks {
@@ -59,6 +178,67 @@ func run(banks []string, digUsed int) int {
 	return total
 }
 
+// best[k-1] is the max k-digit value as a digit string, k in [1..len(bank)]
+func getBankMaxCurve(bank string) []string {
+	best := make([]string, len(bank))
+	for ind, ch := range bank {
+		// go from longest to shortest so that best[k-2] is still the previous state
+		for k := ind + 1; k >= 1; k-- {
+			candidate := string(ch)
+			if k > 1 {
+				candidate = best[k-2] + string(ch)
+			}
+			if best[k-1] == "" || candidate > best[k-1] {
+				best[k-1] = candidate
+			}
+		}
+	}
+	return best
+}
+
+func runCurve(banks []string) ([][]string, []*big.Int) {
+	table := [][]string{}
+	curve := []*big.Int{}
+	for _, bank := range banks {
+		bankCurve := getBankMaxCurve(bank)
+		table = append(table, bankCurve)
+
+		for k, value := range bankCurve {
+			if k >= len(curve) {
+				curve = append(curve, big.NewInt(0))
+			}
+			bankMax, ok := new(big.Int).SetString(value, 10)
+			if !ok {
+				panic("Invalid bank: " + bank)
+			}
+			curve[k].Add(curve[k], bankMax)
+		}
+	}
+	return table, curve
+}
+
+func saveCurveTable(table [][]string, filename string) {
+	var sb strings.Builder
+	for ind, bankCurve := range table {
+		sb.WriteString(fmt.Sprintf("%d", ind))
+		for _, value := range bankCurve {
+			sb.WriteString("," + value)
+		}
+		sb.WriteString("\n")
+	}
+
+	err := os.WriteFile(filename, []byte(sb.String()), 0644)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func printCurve(curve []*big.Int) {
+	for k, total := range curve {
+		fmt.Printf("k=%d: %s\n", k+1, total.String())
+	}
+}
+
 func test(banks []string, digUsed int, exp_total int) bool {
 
 	total := run(banks, digUsed)
@@ -74,6 +254,80 @@ func test(banks []string, digUsed int, exp_total int) bool {
 	}
 }
 
+func testModeStates(bank string, digUsed int, mode Mode, exp_output int) bool {
+
+	ints := []int{}
+	for _, ch := range bank {
+		ints = append(ints, int(ch-'0'))
+	}
+	cache := map[selectionState]selection{}
+	best := selectDigits(ints, selectionState{last: -1, remaining: digUsed, limit: len(ints) - 1}, mode, cache)
+	maxStates := len(ints) * digUsed
+
+	if best.ok && best.digits == fmt.Sprint(exp_output) && len(cache) <= maxStates {
+		fmt.Printf("✅Test passed: %d digits, %d states\n", len(ints), len(cache))
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %d digits\n", len(ints))
+		fmt.Printf("Actual: %s with %d states\n", best.digits, len(cache))
+		fmt.Printf("Expected: %d with at most %d states\n", exp_output, maxStates)
+		return false
+	}
+}
+
+func testMode(banks []string, digUsed int, mode Mode, exp_total int) bool {
+
+	total := runMode(banks, digUsed, mode)
+
+	if total == exp_total {
+		fmt.Printf("✅Test passed: %v %+v\n", banks, mode)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v %+v\n", banks, mode)
+		fmt.Printf("Actual total: %d\n", total)
+		fmt.Printf("Expected total: %d\n", exp_total)
+		return false
+	}
+}
+
+func testCurve(banks []string, exp_curve []string) bool {
+
+	_, curve := runCurve(banks)
+
+	curveStrings := []string{}
+	for _, total := range curve {
+		curveStrings = append(curveStrings, total.String())
+	}
+
+	if fmt.Sprint(curveStrings) == fmt.Sprint(exp_curve) {
+		fmt.Printf("✅Test passed: %v\n", banks)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", banks)
+		fmt.Printf("Actual curve: %v\n", curveStrings)
+		fmt.Printf("Expected curve: %v\n", exp_curve)
+		return false
+	}
+}
+
+func testCurveMatchesRun(banks []string) bool {
+
+	_, curve := runCurve(banks)
+
+	for k := 1; k <= len(curve); k++ {
+		total := run(banks, k)
+		if curve[k-1].String() != fmt.Sprintf("%d", total) {
+			fmt.Printf("❌Test failed: %v\n", banks)
+			fmt.Printf("Curve total for k=%d: %s\n", k, curve[k-1].String())
+			fmt.Printf("Run total for k=%d: %d\n", k, total)
+			return false
+		}
+	}
+
+	fmt.Printf("✅Test passed: %v\n", banks)
+	return true
+}
+
 func readInput(filename string) []string {
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -99,6 +353,38 @@ func main() {
 	success = test([]string{"818181911112111"}, 12, 888911112111) && success
 	success = test([]string{"987654321111111", "811111111111119", "234234234234278", "818181911112111"}, 12, 3121910778619) && success
 
+	success = testCurve([]string{"1"}, []string{"1"}) && success
+	success = testCurve([]string{"312"}, []string{"3", "32", "312"}) && success
+	success = testCurve([]string{"312", "45"}, []string{"8", "77", "312"}) && success
+	success = testCurveMatchesRun([]string{"987654321111111", "811111111111119", "234234234234278", "818181911112111"}) && success
+
+	success = testMode([]string{"987654321111111", "811111111111119", "234234234234278", "818181911112111"}, 2, Mode{}, 357) && success
+	success = testMode([]string{"987654321111111", "811111111111119", "234234234234278", "818181911112111"}, 12, Mode{}, 3121910778619) && success
+
+	success = testMode([]string{"987654321111111"}, 2, Mode{objective: Smallest}, 11) && success
+	success = testMode([]string{"234234234234278"}, 3, Mode{objective: Smallest}, 222) && success
+	success = testMode([]string{"818181911112111"}, 4, Mode{objective: Smallest}, 1111) && success
+
+	success = testMode([]string{"811111111111119"}, 2, Mode{maxGap: 1}, 81) && success
+	success = testMode([]string{"811111111111119"}, 3, Mode{maxGap: 13}, 819) && success
+	success = testMode([]string{"811111111111119"}, 2, Mode{maxGap: 13}, 81) && success
+	success = testMode([]string{"234234234234278"}, 2, Mode{objective: Smallest, maxGap: 1}, 23) && success
+
+	success = testMode([]string{"818181911112111"}, 2, Mode{window: 3}, 91) && success
+	success = testMode([]string{"818181911112111"}, 3, Mode{window: 3}, 911) && success
+	success = testMode([]string{"818181911112111"}, 4, Mode{window: 15}, 9211) && success
+	success = testMode([]string{"987654321"}, 3, Mode{objective: Smallest, window: 3}, 321) && success
+
+	success = testMode([]string{"818181911112111"}, 4, Mode{distinctDigits: true}, 8921) && success
+	success = testMode([]string{"987654321111111"}, 9, Mode{distinctDigits: true}, 987654321) && success
+	success = testMode([]string{"234234234234278"}, 3, Mode{objective: Smallest, distinctDigits: true}, 234) && success
+	success = testMode([]string{"818181911112111"}, 3, Mode{window: 5, distinctDigits: true}, 891) && success
+
+	longBank := strings.Repeat("2342342342342789181819111121118", 4)[:100]
+	success = testModeStates(longBank, 12, Mode{}, getBankMax(longBank, 12)) && success
+	success = testModeStates(longBank, 12, Mode{objective: Smallest}, 111111111111) && success
+	success = testModeStates(longBank, 12, Mode{maxGap: 3}, 988912184444) && success
+
 	if success {
 		fmt.Printf("-=-=-=-=-=-=-=-=-=-=-=-=-\n✅All tests passed!\n")
 	} else {
@@ -108,9 +394,10 @@ func main() {
 
 	input := readInput("input.txt")
 
-	result2 := run(input, 2)
-	fmt.Printf("Result (2 digits): %d\n", result2)
+	table, curve := runCurve(input)
+	saveCurveTable(table, "curve.csv")
+	printCurve(curve)
 
-	result12 := run(input, 12)
-	fmt.Printf("Result (12 digits): %d\n", result12)
+	fmt.Printf("Result (2 digits): %s\n", curve[1].String())
+	fmt.Printf("Result (12 digits): %s\n", curve[11].String())
 }
@@ -1,3 +1,7 @@
 module day4a
 
 go 1.25.4
+
+require grid v0.0.0
+
+replace grid => ../grid
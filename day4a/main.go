@@ -3,43 +3,39 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"grid"
+	"math/bits"
+	"math/rand"
 	"os"
 	"strings"
 )
 
-func checkGridConsistent(grid []string) bool {
-	if len(grid) == 0 {
-		return true
-	}
-	width := len(grid[0])
-	for _, row := range grid {
-		if len(row) != width {
-			return false
-		}
-	}
-	return true
+var defaultRule = grid.Rule{
+	Neighborhood: grid.Moore,
+	Topology:     grid.Bounded,
+	Comparison:   grid.Less,
+	Threshold:    4,
 }
 
-func hasRoll(grid []string, x, y, width, height int) bool {
-	if x < 0 || x >= width || y < 0 || y >= height {
-		return false
-	}
-	return grid[y][x] == '@'
+func parseRoll(ch byte) bool {
+	return ch == '@'
 }
 
-func getNeighbors(grid []string, x, y, width, height int) int {
-	directions := [][2]int{
-		{-1, -1}, {0, -1}, {1, -1},
-		{-1, 0}, {1, 0},
-		{-1, 1}, {0, 1}, {1, 1}}
+func getNeighbors(g *grid.Grid[bool], x, y int, rule grid.Rule) int {
+	neighbors := 0
+	for _, roll := range g.NeighborsIn(rule.Topology, x, y, rule.Neighborhood) {
+		if roll {
+			neighbors++
+		}
+	}
+
+	return neighbors
+}
 
+func getNeighborsN(g *grid.NGrid[bool], index int, offsets [][]int) int {
 	neighbors := 0
-	for dir := range directions {
-		dx := directions[dir][0]
-		dy := directions[dir][1]
-		nx := x + dx
-		ny := y + dy
-		if hasRoll(grid, nx, ny, width, height) {
+	for _, roll := range g.Neighbors(index, offsets) {
+		if roll {
 			neighbors++
 		}
 	}
@@ -47,49 +43,227 @@ func getNeighbors(grid []string, x, y, width, height int) int {
 	return neighbors
 }
 
-func run(grid []string) int {
-	if !checkGridConsistent(grid) {
+func mustParseKernel(lines []string) []grid.Point {
+	offsets, err := grid.ParseKernel(lines)
+	if err != nil {
+		panic(err)
+	}
+	return offsets
+}
+
+func runRule(input []string, rule grid.Rule) int {
+	g, err := grid.Parse(input, parseRoll)
+	if err != nil {
 		panic("Inconsistent grid")
 	}
 
-	width := len(grid[0])
-	height := len(grid)
-	if width == 0 || height == 0 {
-		return 0
+	rolls := 0
+	for p, roll := range g.All() {
+		if !roll {
+			continue
+		}
+
+		neighbors := getNeighbors(g, p.X, p.Y, rule)
+		if rule.Holds(neighbors) {
+			rolls++
+		}
+	}
+
+	return rolls
+}
+
+// layers are separated by blank lines, every extra blank line adds a dimension
+func runLayers(input []string, comparison grid.Comparison, threshold int) int {
+	g, err := grid.ParseLayers(input, parseRoll)
+	if err != nil {
+		panic(err)
 	}
 
+	offsets := grid.MooreN(len(g.Dims()))
 	rolls := 0
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			if !hasRoll(grid, x, y, width, height) {
-				continue
-			}
+	for index, roll := range g.All() {
+		if !roll {
+			continue
+		}
 
-			neighbors := getNeighbors(grid, x, y, width, height)
-			if neighbors < 4 {
-				rolls++
-			}
+		neighbors := getNeighborsN(g, index, offsets)
+		if comparison.Holds(neighbors, threshold) {
+			rolls++
 		}
 	}
 
 	return rolls
 }
 
-func test(grid []string, exp_rolls int) bool {
+func getWord(row []uint64, i int) uint64 {
+	if row == nil || i < 0 || i >= len(row) {
+		return 0
+	}
+	return row[i]
+}
+
+// bit x of the result is the cell x-1 of the row
+func shiftWest(row []uint64, i int) uint64 {
+	return getWord(row, i)<<1 | getWord(row, i-1)>>63
+}
+
+// bit x of the result is the cell x+1 of the row
+func shiftEast(row []uint64, i int) uint64 {
+	return getWord(row, i)>>1 | getWord(row, i+1)<<63
+}
+
+// counter holds 64 4-bit counters sliced by bit: counter[b] has bit b of every counter
+func addToCounter(counter *[4]uint64, value uint64) {
+	carry := value
+	for b := range counter {
+		nextCarry := counter[b] & carry
+		counter[b] ^= carry
+		carry = nextCarry
+	}
+}
+
+func lessThan(counter [4]uint64, threshold int) uint64 {
+	if threshold >= 16 {
+		return ^uint64(0)
+	}
+	if threshold <= 0 {
+		return 0
+	}
+
+	less := uint64(0)
+	equal := ^uint64(0)
+	for b := 3; b >= 0; b-- {
+		if threshold&(1<<b) != 0 {
+			less |= equal &^ counter[b]
+			equal &= counter[b]
+		} else {
+			equal &^= counter[b]
+		}
+	}
+	return less
+}
+
+// counts rolls with less than threshold Moore neighbors, 64 cells at a time
+func countAccessibleBits(g *grid.BitGrid, threshold int) int {
+	rolls := 0
+	for y := 0; y < g.Height(); y++ {
+		up := g.Row(y - 1)
+		row := g.Row(y)
+		down := g.Row(y + 1)
+
+		for i := 0; i < g.WordsPerRow(); i++ {
+			counter := [4]uint64{}
+			addToCounter(&counter, shiftWest(up, i))
+			addToCounter(&counter, getWord(up, i))
+			addToCounter(&counter, shiftEast(up, i))
+			addToCounter(&counter, shiftWest(row, i))
+			addToCounter(&counter, shiftEast(row, i))
+			addToCounter(&counter, shiftWest(down, i))
+			addToCounter(&counter, getWord(down, i))
+			addToCounter(&counter, shiftEast(down, i))
+
+			rolls += bits.OnesCount64(row[i] & lessThan(counter, threshold))
+		}
+	}
+	return rolls
+}
+
+func runBits(input []string) int {
+	g, err := grid.ParseBits(input, parseRoll)
+	if err != nil {
+		panic("Inconsistent grid")
+	}
+
+	return countAccessibleBits(g, defaultRule.Threshold)
+}
+
+func run(input []string) int {
+	return runRule(input, defaultRule)
+}
+
+func test(input []string, exp_rolls int) bool {
+
+	rolls := run(input)
+
+	if rolls == exp_rolls {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual total: %d\n", rolls)
+		fmt.Printf("Expected total: %d\n", exp_rolls)
+		return false
+	}
+}
+
+func testRule(input []string, rule grid.Rule, exp_rolls int) bool {
+
+	rolls := runRule(input, rule)
+
+	if rolls == exp_rolls {
+		fmt.Printf("✅Test passed: %v %+v\n", input, rule)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v %+v\n", input, rule)
+		fmt.Printf("Actual total: %d\n", rolls)
+		fmt.Printf("Expected total: %d\n", exp_rolls)
+		return false
+	}
+}
+
+func testLayers(input []string, comparison grid.Comparison, threshold int, exp_rolls int) bool {
 
-	rolls := run(grid)
+	rolls := runLayers(input, comparison, threshold)
 
 	if rolls == exp_rolls {
-		fmt.Printf("✅Test passed: %v\n", grid)
+		fmt.Printf("✅Test passed: %v\n", input)
 		return true
 	} else {
-		fmt.Printf("❌Test failed: %v\n", grid)
+		fmt.Printf("❌Test failed: %v\n", input)
 		fmt.Printf("Actual total: %d\n", rolls)
 		fmt.Printf("Expected total: %d\n", exp_rolls)
 		return false
 	}
 }
 
+func testBits(input []string) bool {
+
+	rolls := run(input)
+	rollsBits := runBits(input)
+
+	g, err := grid.ReadBits(strings.NewReader(strings.Join(input, "\n")), parseRoll)
+	if err != nil {
+		panic(err)
+	}
+	rollsRead := countAccessibleBits(g, defaultRule.Threshold)
+
+	if rolls == rollsBits && rolls == rollsRead {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual total: %d (read: %d)\n", rollsBits, rollsRead)
+		fmt.Printf("Expected total: %d\n", rolls)
+		return false
+	}
+}
+
+func getRandomGrid(width, height int, seed int64) []string {
+	rng := rand.New(rand.NewSource(seed))
+	input := []string{}
+	for y := 0; y < height; y++ {
+		row := make([]byte, width)
+		for x := range row {
+			row[x] = '.'
+			if rng.Intn(3) != 0 {
+				row[x] = '@'
+			}
+		}
+		input = append(input, string(row))
+	}
+	return input
+}
+
 func readInput(filename string) []string {
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -149,6 +323,91 @@ func main() {
 		"@.@.@@@.@.",
 	}, 13) && success
 
+	success = testRule([]string{".@.", "@@@", ".@."}, grid.Rule{Neighborhood: grid.VonNeumann, Topology: grid.Bounded, Comparison: grid.Less, Threshold: 2}, 4) && success
+	success = testRule([]string{".@.", "@@@", ".@."}, grid.Rule{Neighborhood: grid.VonNeumann, Topology: grid.Bounded, Comparison: grid.GreaterOrEqual, Threshold: 2}, 1) && success
+	success = testRule([]string{".@.", "@@@", ".@."}, grid.Rule{Neighborhood: grid.Hexagonal, Topology: grid.Bounded, Comparison: grid.Less, Threshold: 3}, 4) && success
+	success = testRule([]string{".@.", "@@@", ".@."}, grid.Rule{Neighborhood: grid.Hexagonal, Topology: grid.Bounded, Comparison: grid.Less, Threshold: 5}, 5) && success
+	success = testRule([]string{".@.", "@@@", ".@."}, grid.Rule{Neighborhood: mustParseKernel([]string{"#.#", "...", "#.#"}), Topology: grid.Bounded, Comparison: grid.Less, Threshold: 1}, 1) && success
+	success = testRule([]string{".@.", "@@@", ".@."}, grid.Rule{Neighborhood: mustParseKernel([]string{"#.#", "...", "#.#"}), Topology: grid.Bounded, Comparison: grid.Equal, Threshold: 2}, 4) && success
+	success = testRule([]string{"@@@", "@@@", "@@@"}, grid.Rule{Neighborhood: grid.Moore, Topology: grid.Bounded, Comparison: grid.LessOrEqual, Threshold: 3}, 4) && success
+	success = testRule([]string{"@@@", "@@@", "@@@"}, grid.Rule{Neighborhood: grid.Moore, Topology: grid.Bounded, Comparison: grid.NotEqual, Threshold: 5}, 5) && success
+	success = testRule([]string{"@@@", "@@@", "@@@"}, grid.Rule{Neighborhood: grid.Moore, Topology: grid.Bounded, Comparison: grid.Greater, Threshold: 4}, 5) && success
+
+	success = testRule([]string{"@@@", "@@@", "@@@"}, grid.Rule{Neighborhood: grid.Moore, Topology: grid.Toroidal, Comparison: grid.Less, Threshold: 4}, 0) && success
+	success = testRule([]string{"@..", "...", "..@"}, grid.Rule{Neighborhood: grid.Moore, Topology: grid.Bounded, Comparison: grid.GreaterOrEqual, Threshold: 1}, 0) && success
+	success = testRule([]string{"@..", "...", "..@"}, grid.Rule{Neighborhood: grid.Moore, Topology: grid.Toroidal, Comparison: grid.GreaterOrEqual, Threshold: 1}, 2) && success
+	success = testRule([]string{"@...@", "....."}, grid.Rule{Neighborhood: grid.VonNeumann, Topology: grid.Toroidal, Comparison: grid.Equal, Threshold: 1}, 2) && success
+
+	success = testBits([]string{""}) && success
+	success = testBits([]string{"@"}) && success
+	success = testBits([]string{".@.", "@@@", ".@."}) && success
+	success = testBits([]string{".@.", "@@@", ".@.", ""}) && success
+	success = testBits([]string{".@.", "@@@", ".@.", "", ""}) && success
+	success = testBits([]string{"@@@", "@@@", "@@@"}) && success
+	success = testBits([]string{
+		"..@@.@@@@.",
+		"@@@.@.@.@@",
+		"@@@@@.@.@@",
+		"@.@@@@..@.",
+		"@@.@@@@.@@",
+		".@@@@@@@.@",
+		".@.@.@.@@@",
+		"@.@@@.@@@@",
+		".@@@@@@@@.",
+		"@.@.@@@.@.",
+	}) && success
+	success = testBits(getRandomGrid(64, 5, 1)) && success
+	success = testBits(getRandomGrid(65, 7, 2)) && success
+	success = testBits(getRandomGrid(200, 50, 3)) && success
+
+	success = testLayers([]string{".@.", "@@@", ".@."}, grid.Less, 4, 4) && success
+	success = testLayers([]string{
+		"..@@.@@@@.",
+		"@@@.@.@.@@",
+		"@@@@@.@.@@",
+		"@.@@@@..@.",
+		"@@.@@@@.@@",
+		".@@@@@@@.@",
+		".@.@.@.@@@",
+		"@.@@@.@@@@",
+		".@@@@@@@@.",
+		"@.@.@@@.@.",
+		"",
+	}, grid.Less, 4, 13) && success
+	success = testLayers([]string{"...", ".@.", "...", "", "...", "...", "...", "", "...", "...", "..."}, grid.Less, 4, 1) && success
+	success = testLayers([]string{"@@", "@@", "", "@@", "@@"}, grid.Less, 4, 0) && success
+	success = testLayers([]string{"@@", "@@", "", "@@", "@@"}, grid.LessOrEqual, 7, 8) && success
+	success = testLayers([]string{"@.", "..", "", "..", ".@"}, grid.Less, 1, 0) && success
+	success = testLayers([]string{"@.", "..", "", ".@", ".."}, grid.Less, 1, 0) && success
+	success = testLayers([]string{"@.", "..", "", "..", "..", "", "..", ".@"}, grid.Less, 1, 2) && success
+	success = testLayers([]string{
+		"@@@", "@@@", "@@@",
+		"",
+		"@@@", "@@@", "@@@",
+		"",
+		"@@@", "@@@", "@@@",
+	}, grid.Less, 8, 8) && success
+	success = testLayers([]string{
+		"@@", "@@",
+		"",
+		"@@", "@@",
+		"",
+		"",
+		"@@", "@@",
+		"",
+		"@@", "@@",
+	}, grid.Less, 16, 16) && success
+	success = testLayers([]string{
+		"@@", "@@",
+		"",
+		"@@", "@@",
+		"",
+		"",
+		"@@", "@@",
+		"",
+		"@@", "@@",
+	}, grid.Less, 15, 0) && success
+
 	if success {
 		fmt.Printf("-=-=-=-=-=-=-=-=-=-=-=-=-\n✅All tests passed!\n")
 	} else {
@@ -160,4 +419,16 @@ func main() {
 
 	result := run(input)
 	fmt.Printf("Result: %d\n", result)
+
+	file, err := os.Open("input.txt")
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	bitGrid, err := grid.ReadBits(file, parseRoll)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Result (bitset): %d\n", countAccessibleBits(bitGrid, defaultRule.Threshold))
 }
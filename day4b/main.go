@@ -2,44 +2,38 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"grid"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"iter"
 	"os"
+	"sort"
 	"strings"
 )
 
-func checkGridConsistent(grid []string) bool {
-	if len(grid) == 0 {
-		return true
-	}
-	width := len(grid[0])
-	for _, row := range grid {
-		if len(row) != width {
-			return false
-		}
-	}
-	return true
-}
+// rounds: 0 is emptiness, -1 is a roll that is never removed,
+// any positive value is the round in which the roll was removed
+const remainingRoll = -1
 
-func hasRoll(grid []string, x, y, width, height int) bool {
-	if x < 0 || x >= width || y < 0 || y >= height {
-		return false
-	}
-	return grid[y][x] == '@'
+var defaultRule = grid.Rule{
+	Neighborhood: grid.Moore,
+	Topology:     grid.Bounded,
+	Comparison:   grid.Less,
+	Threshold:    4,
 }
 
-func getNeighbors(grid []string, x, y, width, height int) int {
-	directions := [][2]int{
-		{-1, -1}, {0, -1}, {1, -1},
-		{-1, 0}, {1, 0},
-		{-1, 1}, {0, 1}, {1, 1}}
+func parseRoll(ch byte) bool {
+	return ch == '@'
+}
 
+func getNeighbors(g *grid.Grid[bool], x, y int, rule grid.Rule) int {
 	neighbors := 0
-	for dir := range directions {
-		dx := directions[dir][0]
-		dy := directions[dir][1]
-		nx := x + dx
-		ny := y + dy
-		if hasRoll(grid, nx, ny, width, height) {
+	for _, roll := range g.NeighborsIn(rule.Topology, x, y, rule.Neighborhood) {
+		if roll {
 			neighbors++
 		}
 	}
@@ -47,66 +41,637 @@ func getNeighbors(grid []string, x, y, width, height int) int {
 	return neighbors
 }
 
-func removeRoll(grid []string, x, y int) {
-	row := []rune(grid[y])
-	row[x] = '.'
-	grid[y] = string(row)
+func getNeighborsN(g *grid.NGrid[bool], index int, offsets [][]int) int {
+	neighbors := 0
+	for _, roll := range g.Neighbors(index, offsets) {
+		if roll {
+			neighbors++
+		}
+	}
+
+	return neighbors
 }
 
-func run(grid []string) int {
-	if !checkGridConsistent(grid) {
-		panic("Inconsistent grid")
+func mustParseKernel(lines []string) []grid.Point {
+	offsets, err := grid.ParseKernel(lines)
+	if err != nil {
+		panic(err)
 	}
+	return offsets
+}
 
-	width := len(grid[0])
-	height := len(grid)
-	if width == 0 || height == 0 {
-		return 0
+func renderRoll(roll bool) byte {
+	if roll {
+		return '@'
 	}
+	return '.'
+}
 
-	rolls := 0
-	removed := true
-	grid_copy := make([]string, height)
-	copy(grid_copy, grid)
+// rollSet is what the peeling worklist needs from a grid of rolls, K is a cell position
+type rollSet[K any] interface {
+	All() iter.Seq2[K, bool]
+	Len() int
+	Index(k K) int // dense index below Len
+	CountNeighbors(k K) int
+	// Watchers are the cells whose neighborhood contains k
+	Watchers(k K) iter.Seq2[K, bool]
+	Remove(k K, round int)
+}
+
+// peelWorklist removes every roll the rule holds for, round after round, and returns how many went in each round.
+// Only rolls whose neighborhood contains a removed roll can change their accessibility,
+// so every round re-checks just those instead of the whole grid.
+func peelWorklist[K any](rolls rollSet[K], holds func(neighbors int) bool) []int {
+	counts := make([]int, rolls.Len())
+	current := []K{}
+	for k, roll := range rolls.All() {
+		if !roll {
+			continue
+		}
+
+		counts[rolls.Index(k)] = rolls.CountNeighbors(k)
+		if holds(counts[rolls.Index(k)]) {
+			current = append(current, k)
+		}
+	}
 
-	for removed {
-		removed = false
-		copy(grid, grid_copy)
+	removedPerRound := []int{}
+	touched := make([]bool, rolls.Len())
+	for round := 1; len(current) > 0; round++ {
+		for _, k := range current {
+			rolls.Remove(k, round)
+		}
+		removedPerRound = append(removedPerRound, len(current))
 
-		for y := 0; y < height; y++ {
-			for x := 0; x < width; x++ {
-				if !hasRoll(grid, x, y, width, height) {
+		candidates := []K{}
+		for _, k := range current {
+			for n, roll := range rolls.Watchers(k) {
+				if !roll {
 					continue
 				}
 
-				neighbors := getNeighbors(grid, x, y, width, height)
-				if neighbors < 4 {
-					removed = true
-					rolls++
-					removeRoll(grid_copy, x, y)
+				index := rolls.Index(n)
+				counts[index]--
+				if !touched[index] {
+					touched[index] = true
+					candidates = append(candidates, n)
 				}
 			}
 		}
+
+		next := []K{}
+		for _, n := range candidates {
+			index := rolls.Index(n)
+			touched[index] = false
+			if holds(counts[index]) {
+				next = append(next, n)
+			}
+		}
+		current = next
+	}
+
+	return removedPerRound
+}
+
+// planeRolls peels a 2D grid and records the round of every removed roll
+type planeRolls struct {
+	g        *grid.Grid[bool]
+	rule     grid.Rule
+	watchers []grid.Point // the negated offsets, they differ for one-sided kernels
+	rounds   *grid.Grid[int]
+}
+
+func (r planeRolls) All() iter.Seq2[grid.Point, bool] {
+	return r.g.All()
+}
+
+func (r planeRolls) Len() int {
+	return r.g.Width() * r.g.Height()
+}
+
+func (r planeRolls) Index(p grid.Point) int {
+	return p.Y*r.g.Width() + p.X
+}
+
+func (r planeRolls) CountNeighbors(p grid.Point) int {
+	return getNeighbors(r.g, p.X, p.Y, r.rule)
+}
+
+func (r planeRolls) Watchers(p grid.Point) iter.Seq2[grid.Point, bool] {
+	return r.g.NeighborsIn(r.rule.Topology, p.X, p.Y, r.watchers)
+}
+
+func (r planeRolls) Remove(p grid.Point, round int) {
+	r.g.Set(p.X, p.Y, false)
+	r.rounds.Set(p.X, p.Y, round)
+}
+
+func peelRounds(g *grid.Grid[bool], rule grid.Rule) (*grid.Grid[int], []int) {
+	rounds := grid.New(g.Width(), g.Height(), 0)
+	for p, roll := range g.All() {
+		if roll {
+			rounds.Set(p.X, p.Y, remainingRoll)
+		}
 	}
 
+	rolls := planeRolls{g: g, rule: rule, watchers: grid.Negate(rule.Neighborhood), rounds: rounds}
+	removedPerRound := peelWorklist(rolls, rule.Holds)
+	return rounds, removedPerRound
+}
+
+func peel(g *grid.Grid[bool], rule grid.Rule) int {
+	_, removedPerRound := peelRounds(g, rule)
+
+	rolls := 0
+	for _, removed := range removedPerRound {
+		rolls += removed
+	}
 	return rolls
 }
 
-func test(grid []string, exp_rolls int) bool {
+type Component struct {
+	Size int `json:"size"`
+	MinX int `json:"minX"`
+	MinY int `json:"minY"`
+	MaxX int `json:"maxX"`
+	MaxY int `json:"maxY"`
+}
+
+type RollDepth struct {
+	X     int `json:"x"`
+	Y     int `json:"y"`
+	Depth int `json:"depth"`
+}
+
+// depth is the distance in neighborhood steps to the nearest removed roll, -1 if nothing was removed
+type CoreAnalysis struct {
+	Components []Component `json:"components"`
+	MaxDepth   int         `json:"maxDepth"`
+	Depths     []RollDepth `json:"depths"`
+}
+
+// custom kernels may be one-sided, but connectivity and distance must work both ways
+func getSymmetricNeighborhood(neighborhood []grid.Point) []grid.Point {
+	seen := map[grid.Point]bool{}
+	symmetric := []grid.Point{}
+	for _, offset := range neighborhood {
+		for _, o := range []grid.Point{offset, {X: -offset.X, Y: -offset.Y}} {
+			if !seen[o] {
+				seen[o] = true
+				symmetric = append(symmetric, o)
+			}
+		}
+	}
+	return symmetric
+}
+
+func getComponents(rounds *grid.Grid[int], rule grid.Rule) []Component {
+	neighborhood := getSymmetricNeighborhood(rule.Neighborhood)
+	visited := grid.New(rounds.Width(), rounds.Height(), false)
+	components := []Component{}
+
+	for p, round := range rounds.All() {
+		if round != remainingRoll || visited.At(p.X, p.Y) {
+			continue
+		}
+
+		// bounding boxes are in grid coordinates, even on a toroidal grid
+		component := Component{MinX: p.X, MinY: p.Y, MaxX: p.X, MaxY: p.Y}
+		visited.Set(p.X, p.Y, true)
+		queue := []grid.Point{p}
+		for len(queue) > 0 {
+			cur := queue[0]
+			queue = queue[1:]
+
+			component.Size++
+			component.MinX = min(component.MinX, cur.X)
+			component.MinY = min(component.MinY, cur.Y)
+			component.MaxX = max(component.MaxX, cur.X)
+			component.MaxY = max(component.MaxY, cur.Y)
+
+			for n, nRound := range rounds.NeighborsIn(rule.Topology, cur.X, cur.Y, neighborhood) {
+				if nRound != remainingRoll || visited.At(n.X, n.Y) {
+					continue
+				}
+				visited.Set(n.X, n.Y, true)
+				queue = append(queue, n)
+			}
+		}
+		components = append(components, component)
+	}
 
-	rolls := run(grid)
+	sort.SliceStable(components, func(i, j int) bool {
+		return components[i].Size > components[j].Size
+	})
+	return components
+}
+
+func getDepths(rounds *grid.Grid[int], rule grid.Rule) *grid.Grid[int] {
+	neighborhood := getSymmetricNeighborhood(rule.Neighborhood)
+	depths := grid.New(rounds.Width(), rounds.Height(), -1)
+
+	queue := []grid.Point{}
+	for p, round := range rounds.All() {
+		if round > 0 {
+			depths.Set(p.X, p.Y, 0)
+			queue = append(queue, p)
+		}
+	}
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		for n, depth := range depths.NeighborsIn(rule.Topology, cur.X, cur.Y, neighborhood) {
+			if depth != -1 {
+				continue
+			}
+			depths.Set(n.X, n.Y, depths.At(cur.X, cur.Y)+1)
+			queue = append(queue, n)
+		}
+	}
+
+	return depths
+}
+
+func analyzeCore(rounds *grid.Grid[int], rule grid.Rule) CoreAnalysis {
+	analysis := CoreAnalysis{
+		Components: getComponents(rounds, rule),
+		MaxDepth:   -1,
+		Depths:     []RollDepth{},
+	}
+
+	depths := getDepths(rounds, rule)
+	for p, round := range rounds.All() {
+		if round != remainingRoll {
+			continue
+		}
+		depth := depths.At(p.X, p.Y)
+		analysis.MaxDepth = max(analysis.MaxDepth, depth)
+		analysis.Depths = append(analysis.Depths, RollDepth{X: p.X, Y: p.Y, Depth: depth})
+	}
+
+	return analysis
+}
+
+func renderDepth(depth int) byte {
+	if depth < 0 {
+		return '.'
+	}
+	if depth > 9 {
+		return '+'
+	}
+	return byte('0' + depth)
+}
+
+func formatCoreAnalysis(analysis CoreAnalysis, width, height int) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Components: %d\n", len(analysis.Components)))
+	for ind, c := range analysis.Components {
+		sb.WriteString(fmt.Sprintf("Component %d: size %d, bounding box (%d,%d)-(%d,%d)\n", ind+1, c.Size, c.MinX, c.MinY, c.MaxX, c.MaxY))
+	}
+	sb.WriteString(fmt.Sprintf("Max depth: %d\n", analysis.MaxDepth))
+
+	// rolls that can't reach a removed one are shown as '?'
+	depthMap := grid.New(width, height, byte('.'))
+	for _, d := range analysis.Depths {
+		if d.Depth < 0 {
+			depthMap.Set(d.X, d.Y, '?')
+		} else {
+			depthMap.Set(d.X, d.Y, renderDepth(d.Depth))
+		}
+	}
+	sb.WriteString("Depths:\n")
+	sb.WriteString(depthMap.Render(func(ch byte) byte { return ch }))
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+func saveCoreAnalysis(analysis CoreAnalysis, width, height int, textFilename, jsonFilename string) {
+	err := os.WriteFile(textFilename, []byte(formatCoreAnalysis(analysis, width, height)), 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	data, err := json.MarshalIndent(analysis, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	err = os.WriteFile(jsonFilename, data, 0644)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func getRoundColor(round, maxRound int) color.RGBA {
+	if round == 0 {
+		return color.RGBA{0, 0, 0, 255} // Black
+	}
+	if round == remainingRoll {
+		return color.RGBA{0, 255, 0, 255} // Green
+	}
+	// Red for the first round up to yellow for the last one
+	green := uint8(0)
+	if maxRound > 1 {
+		green = uint8(255 * (round - 1) / (maxRound - 1))
+	}
+	return color.RGBA{255, green, 0, 255}
+}
+
+func saveRoundsToPng(rounds *grid.Grid[int], maxRound int, filename string) {
+	img := image.NewRGBA(image.Rect(0, 0, rounds.Width(), rounds.Height()))
+
+	for p, round := range rounds.All() {
+		img.Set(p.X, p.Y, getRoundColor(round, maxRound))
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	err = png.Encode(file, img)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func saveRoundsToGif(rounds *grid.Grid[int], maxRound int, filename string) {
+	palette := color.Palette{
+		color.RGBA{0, 0, 0, 255},       // Black
+		color.RGBA{255, 255, 255, 255}, // White
+	}
+
+	anim := &gif.GIF{}
+	// frame N shows the grid after N rounds
+	for frame := 0; frame <= maxRound; frame++ {
+		img := image.NewPaletted(image.Rect(0, 0, rounds.Width(), rounds.Height()), palette)
+		for p, round := range rounds.All() {
+			if round == remainingRoll || round > frame {
+				img.SetColorIndex(p.X, p.Y, 1)
+			}
+		}
+		anim.Image = append(anim.Image, img)
+		anim.Delay = append(anim.Delay, 20)
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	err = gif.EncodeAll(file, anim)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func saveRoundCounts(removedPerRound []int, filename string) {
+	var sb strings.Builder
+	sb.WriteString("round,removed\n")
+	for ind, removed := range removedPerRound {
+		sb.WriteString(fmt.Sprintf("%d,%d\n", ind+1, removed))
+	}
+
+	err := os.WriteFile(filename, []byte(sb.String()), 0644)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// spaceRolls peels an N-D grid, the Moore neighborhood is symmetric so it is its own watcher
+type spaceRolls struct {
+	g       *grid.NGrid[bool]
+	offsets [][]int
+}
+
+func (r spaceRolls) All() iter.Seq2[int, bool] {
+	return r.g.All()
+}
+
+func (r spaceRolls) Len() int {
+	return r.g.Len()
+}
+
+func (r spaceRolls) Index(index int) int {
+	return index
+}
+
+func (r spaceRolls) CountNeighbors(index int) int {
+	return getNeighborsN(r.g, index, r.offsets)
+}
+
+func (r spaceRolls) Watchers(index int) iter.Seq2[int, bool] {
+	return r.g.Neighbors(index, r.offsets)
+}
+
+func (r spaceRolls) Remove(index, round int) {
+	r.g.Set(index, false)
+}
+
+func peelLayers(g *grid.NGrid[bool], comparison grid.Comparison, threshold int) []int {
+	rolls := spaceRolls{g: g, offsets: grid.MooreN(len(g.Dims()))}
+	return peelWorklist(rolls, func(neighbors int) bool {
+		return comparison.Holds(neighbors, threshold)
+	})
+}
+
+// layers are separated by blank lines, every extra blank line adds a dimension
+func runLayers(input []string, comparison grid.Comparison, threshold int) []int {
+	g, err := grid.ParseLayers(input, parseRoll)
+	if err != nil {
+		panic(err)
+	}
+
+	return peelLayers(g, comparison, threshold)
+}
+
+func runRule(input []string, rule grid.Rule) int {
+	g, err := grid.Parse(input, parseRoll)
+	if err != nil {
+		panic("Inconsistent grid")
+	}
+
+	return peel(g, rule)
+}
+
+func run(input []string) int {
+	return runRule(input, defaultRule)
+}
+
+func test(input []string, exp_rolls int) bool {
+
+	rolls := run(input)
 
 	if rolls == exp_rolls {
-		fmt.Printf("✅Test passed: %v\n", grid)
+		fmt.Printf("✅Test passed: %v\n", input)
 		return true
 	} else {
-		fmt.Printf("❌Test failed: %v\n", grid)
+		fmt.Printf("❌Test failed: %v\n", input)
 		fmt.Printf("Actual total: %d\n", rolls)
 		fmt.Printf("Expected total: %d\n", exp_rolls)
 		return false
 	}
 }
 
+func testRemaining(input []string, exp_remaining []string) bool {
+
+	g, err := grid.Parse(input, parseRoll)
+	if err != nil {
+		panic("Inconsistent grid")
+	}
+	peel(g, defaultRule)
+	remaining := g.Lines(renderRoll)
+
+	if strings.Join(remaining, "\n") == strings.Join(exp_remaining, "\n") {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual remaining: %v\n", remaining)
+		fmt.Printf("Expected remaining: %v\n", exp_remaining)
+		return false
+	}
+}
+
+func testRounds(input []string, rule grid.Rule, exp_removedPerRound []int) bool {
+
+	g, err := grid.Parse(input, parseRoll)
+	if err != nil {
+		panic("Inconsistent grid")
+	}
+	_, removedPerRound := peelRounds(g, rule)
+
+	if fmt.Sprint(removedPerRound) == fmt.Sprint(exp_removedPerRound) {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual removed per round: %v\n", removedPerRound)
+		fmt.Printf("Expected removed per round: %v\n", exp_removedPerRound)
+		return false
+	}
+}
+
+// rescanRounds peels by checking every roll of the grid in every round
+func rescanRounds(g *grid.Grid[bool], rule grid.Rule) (*grid.Grid[int], []int) {
+	rounds := grid.New(g.Width(), g.Height(), 0)
+	for p, roll := range g.All() {
+		if roll {
+			rounds.Set(p.X, p.Y, remainingRoll)
+		}
+	}
+
+	removedPerRound := []int{}
+	for round := 1; ; round++ {
+		current := []grid.Point{}
+		for p, roll := range g.All() {
+			if roll && rule.Holds(getNeighbors(g, p.X, p.Y, rule)) {
+				current = append(current, p)
+			}
+		}
+		if len(current) == 0 {
+			return rounds, removedPerRound
+		}
+
+		for _, p := range current {
+			g.Set(p.X, p.Y, false)
+			rounds.Set(p.X, p.Y, round)
+		}
+		removedPerRound = append(removedPerRound, len(current))
+	}
+}
+
+func testRescan(input []string, rule grid.Rule, exp_removedPerRound []int) bool {
+
+	g, err := grid.Parse(input, parseRoll)
+	if err != nil {
+		panic("Inconsistent grid")
+	}
+	rounds, removedPerRound := peelRounds(g.Clone(), rule)
+	expRounds, expRemovedPerRound := rescanRounds(g.Clone(), rule)
+
+	renderRound := func(round int) byte {
+		if round == remainingRoll {
+			return '@'
+		}
+		return byte('0' + round%10)
+	}
+	if fmt.Sprint(removedPerRound) == fmt.Sprint(expRemovedPerRound) &&
+		fmt.Sprint(removedPerRound) == fmt.Sprint(exp_removedPerRound) &&
+		rounds.Render(renderRound) == expRounds.Render(renderRound) {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual removed per round: %v\n%s\n", removedPerRound, rounds.Render(renderRound))
+		fmt.Printf("Rescan removed per round: %v\n%s\n", expRemovedPerRound, expRounds.Render(renderRound))
+		fmt.Printf("Expected removed per round: %v\n", exp_removedPerRound)
+		return false
+	}
+}
+
+func testCore(input []string, rule grid.Rule, exp_text string) bool {
+
+	g, err := grid.Parse(input, parseRoll)
+	if err != nil {
+		panic("Inconsistent grid")
+	}
+	rounds, _ := peelRounds(g, rule)
+	text := formatCoreAnalysis(analyzeCore(rounds, rule), g.Width(), g.Height())
+
+	if text == exp_text {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual analysis:\n%s", text)
+		fmt.Printf("Expected analysis:\n%s", exp_text)
+		return false
+	}
+}
+
+func testCoreJson(input []string, rule grid.Rule, exp_json string) bool {
+
+	g, err := grid.Parse(input, parseRoll)
+	if err != nil {
+		panic("Inconsistent grid")
+	}
+	rounds, _ := peelRounds(g, rule)
+	data, err := json.Marshal(analyzeCore(rounds, rule))
+	if err != nil {
+		panic(err)
+	}
+
+	if string(data) == exp_json {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual json: %s\n", data)
+		fmt.Printf("Expected json: %s\n", exp_json)
+		return false
+	}
+}
+
+func testLayers(input []string, comparison grid.Comparison, threshold int, exp_removedPerRound []int) bool {
+
+	removedPerRound := runLayers(input, comparison, threshold)
+
+	if fmt.Sprint(removedPerRound) == fmt.Sprint(exp_removedPerRound) {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual removed per round: %v\n", removedPerRound)
+		fmt.Printf("Expected removed per round: %v\n", exp_removedPerRound)
+		return false
+	}
+}
+
 func readInput(filename string) []string {
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -166,6 +731,157 @@ func main() {
 		"@.@.@@@.@.",
 	}, 43) && success
 
+	success = testRemaining([]string{"@@", "@."}, []string{"..", ".."}) && success
+	success = testRemaining([]string{
+		"..@@.@@@@.",
+		"@@@.@.@.@@",
+		"@@@@@.@.@@",
+		"@.@@@@..@.",
+		"@@.@@@@.@@",
+		".@@@@@@@.@",
+		".@.@.@.@@@",
+		"@.@@@.@@@@",
+		".@@@@@@@@.",
+		"@.@.@@@.@.",
+	}, []string{
+		"..........",
+		"..........",
+		"..........",
+		"....@@....",
+		"...@@@@...",
+		"...@@@@@..",
+		"...@.@.@@.",
+		"...@@.@@@.",
+		"...@@@@@..",
+		"....@@@...",
+	}) && success
+
+	success = testRounds([]string{"..."}, defaultRule, []int{}) && success
+	success = testRounds([]string{"@@", "@."}, defaultRule, []int{3}) && success
+	success = testRounds([]string{".@.", "@@@", ".@."}, defaultRule, []int{4, 1}) && success
+	success = testRounds([]string{
+		"..@@.@@@@.",
+		"@@@.@.@.@@",
+		"@@@@@.@.@@",
+		"@.@@@@..@.",
+		"@@.@@@@.@@",
+		".@@@@@@@.@",
+		".@.@.@.@@@",
+		"@.@@@.@@@@",
+		".@@@@@@@@.",
+		"@.@.@@@.@.",
+	}, defaultRule, []int{13, 12, 7, 5, 2, 1, 1, 1, 1}) && success
+
+	success = testRounds([]string{"@@@", "@@@", "@@@"}, grid.Rule{Neighborhood: grid.VonNeumann, Topology: grid.Bounded, Comparison: grid.Less, Threshold: 2}, []int{}) && success
+	success = testRounds([]string{"@@@", "@@@", "@@@"}, grid.Rule{Neighborhood: grid.VonNeumann, Topology: grid.Bounded, Comparison: grid.Less, Threshold: 3}, []int{4, 4, 1}) && success
+	success = testRounds([]string{"@@@", "@@@", "@@@"}, grid.Rule{Neighborhood: grid.Moore, Topology: grid.Bounded, Comparison: grid.Equal, Threshold: 3}, []int{4, 4}) && success
+	success = testRounds([]string{"@@@", "@@@", "@@@"}, grid.Rule{Neighborhood: grid.Moore, Topology: grid.Toroidal, Comparison: grid.Less, Threshold: 4}, []int{}) && success
+	success = testRounds([]string{".@.", "@@@", ".@."}, grid.Rule{Neighborhood: grid.Hexagonal, Topology: grid.Bounded, Comparison: grid.Less, Threshold: 3}, []int{4, 1}) && success
+	success = testRounds([]string{".@.", "@@@", ".@."}, grid.Rule{Neighborhood: mustParseKernel([]string{"#.#", "...", "#.#"}), Topology: grid.Bounded, Comparison: grid.Less, Threshold: 1}, []int{1}) && success
+	success = testRounds([]string{"@@@@@", "@@@@@", "@@@@@"}, grid.Rule{Neighborhood: grid.Moore, Topology: grid.Toroidal, Comparison: grid.LessOrEqual, Threshold: 8}, []int{15}) && success
+	success = testRounds([]string{"@@@@@", "@@@@@", "@@@@@"}, grid.Rule{Neighborhood: grid.Moore, Topology: grid.Toroidal, Comparison: grid.Less, Threshold: 8}, []int{}) && success
+
+	success = testRescan([]string{"@@@@", "@@@@", "@@@@"}, grid.Rule{Neighborhood: mustParseKernel([]string{"##.", "...", "..."}), Topology: grid.Bounded, Comparison: grid.Less, Threshold: 2}, []int{6, 4, 2}) && success
+	success = testRescan([]string{"@@@@", "@@@@", "@@@@"}, grid.Rule{Neighborhood: mustParseKernel([]string{"##.", "...", "..."}), Topology: grid.Toroidal, Comparison: grid.Less, Threshold: 2}, []int{}) && success
+	success = testRescan([]string{"@@@@", "@@@@", "@@@@"}, grid.Rule{Neighborhood: mustParseKernel([]string{".#.", "...", "#.#"}), Topology: grid.Bounded, Comparison: grid.Less, Threshold: 2}, []int{6, 4, 2}) && success
+	success = testRescan([]string{".@@.", "@@@@", "@@@@"}, grid.Rule{Neighborhood: mustParseKernel([]string{".#.", "...", "#.#"}), Topology: grid.Bounded, Comparison: grid.Less, Threshold: 2}, []int{6, 4}) && success
+	success = testRescan([]string{
+		"..@@.@@@@.",
+		"@@@.@.@.@@",
+		"@@@@@.@.@@",
+		"@.@@@@..@.",
+		"@@.@@@@.@@",
+		".@@@@@@@.@",
+		".@.@.@.@@@",
+		"@.@@@.@@@@",
+		".@@@@@@@@.",
+		"@.@.@@@.@.",
+	}, grid.Rule{Neighborhood: mustParseKernel([]string{"#.#", "#..", "..."}), Topology: grid.Bounded, Comparison: grid.Less, Threshold: 3}, []int{52, 18, 1}) && success
+
+	success = testCore([]string{"@", "."}, defaultRule, "Components: 0\nMax depth: -1\nDepths:\n.\n.\n") && success
+	success = testCore([]string{
+		"@@@@@@..@@@@",
+		"@@@@@@..@@@@",
+		"@@@@@@..@@@@",
+		"@@@@@@..@@@@",
+		"@@@@@@......",
+		"@@@@@@......",
+	}, defaultRule, ""+
+		"Components: 2\n"+
+		"Component 1: size 32, bounding box (0,0)-(5,5)\n"+
+		"Component 2: size 12, bounding box (8,0)-(11,3)\n"+
+		"Max depth: 2\n"+
+		"Depths:\n"+
+		".1221....11.\n"+
+		"112211..1111\n"+
+		"222222..1111\n"+
+		"222222...11.\n"+
+		"112211......\n"+
+		".1221.......\n") && success
+	success = testCore([]string{
+		"@@@@@@..@@@@",
+		"@@@@@@..@@@@",
+		"@@@@@@..@@@@",
+		"@@@@@@..@@@@",
+		"@@@@@@......",
+		"@@@@@@......",
+	}, grid.Rule{Neighborhood: grid.Moore, Topology: grid.Toroidal, Comparison: grid.Less, Threshold: 4}, ""+
+		"Components: 1\n"+
+		"Component 1: size 50, bounding box (0,0)-(11,5)\n"+
+		"Max depth: 6\n"+
+		"Depths:\n"+
+		"456543...123\n"+
+		"456543..1123\n"+
+		"456543..1123\n"+
+		"456543...123\n"+
+		"456543......\n"+
+		"456543......\n") && success
+	success = testCoreJson([]string{"@@", "@@"}, grid.Rule{Neighborhood: grid.Moore, Topology: grid.Bounded, Comparison: grid.Less, Threshold: 3}, ``+
+		`{"components":[{"size":4,"minX":0,"minY":0,"maxX":1,"maxY":1}],"maxDepth":-1,"depths":[`+
+		`{"x":0,"y":0,"depth":-1},{"x":1,"y":0,"depth":-1},{"x":0,"y":1,"depth":-1},{"x":1,"y":1,"depth":-1}]}`) && success
+	success = testCoreJson([]string{"@@", "@@", "@."}, grid.Rule{Neighborhood: grid.VonNeumann, Topology: grid.Bounded, Comparison: grid.Less, Threshold: 2}, ``+
+		`{"components":[{"size":4,"minX":0,"minY":0,"maxX":1,"maxY":1}],"maxDepth":3,"depths":[`+
+		`{"x":0,"y":0,"depth":2},{"x":1,"y":0,"depth":3},{"x":0,"y":1,"depth":1},{"x":1,"y":1,"depth":2}]}`) && success
+
+	success = testLayers([]string{
+		"..@@.@@@@.",
+		"@@@.@.@.@@",
+		"@@@@@.@.@@",
+		"@.@@@@..@.",
+		"@@.@@@@.@@",
+		".@@@@@@@.@",
+		".@.@.@.@@@",
+		"@.@@@.@@@@",
+		".@@@@@@@@.",
+		"@.@.@@@.@.",
+	}, grid.Less, 4, []int{13, 12, 7, 5, 2, 1, 1, 1, 1}) && success
+	success = testLayers([]string{"@@", "@@", "", "@@", "@@"}, grid.Less, 4, []int{}) && success
+	success = testLayers([]string{"@@", "@@", "", "@@", "@@"}, grid.Less, 8, []int{8}) && success
+	success = testLayers([]string{
+		"@@@", "@@@", "@@@",
+		"",
+		"@@@", "@@@", "@@@",
+		"",
+		"@@@", "@@@", "@@@",
+	}, grid.Less, 8, []int{8}) && success
+	success = testLayers([]string{
+		"@@@", "@@@", "@@@",
+		"",
+		"@@@", "@@@", "@@@",
+		"",
+		"@@@", "@@@", "@@@",
+	}, grid.Less, 10, []int{8, 12, 7}) && success
+	success = testLayers([]string{
+		"@@", "@@",
+		"",
+		"@@", "@@",
+		"",
+		"",
+		"@@", "@@",
+		"",
+		"@@", "@@",
+	}, grid.Less, 16, []int{16}) && success
+
 	if success {
 		fmt.Printf("-=-=-=-=-=-=-=-=-=-=-=-=-\n✅All tests passed!\n")
 	} else {
@@ -177,4 +893,14 @@ func main() {
 
 	result := run(input)
 	fmt.Printf("Result: %d\n", result)
+
+	g, err := grid.Parse(input, parseRoll)
+	if err != nil {
+		panic("Inconsistent grid")
+	}
+	rounds, removedPerRound := peelRounds(g, defaultRule)
+	saveRoundsToPng(rounds, len(removedPerRound), "rounds.png")
+	saveRoundsToGif(rounds, len(removedPerRound), "rounds.gif")
+	saveRoundCounts(removedPerRound, "rounds.csv")
+	saveCoreAnalysis(analyzeCore(rounds, defaultRule), rounds.Width(), rounds.Height(), "core.txt", "core.json")
 }
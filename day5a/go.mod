@@ -1,3 +1,12 @@
 module day5a
 
 go 1.25.4
+
+require (
+	intervalset v0.0.0
+	inventory v0.0.0
+)
+
+replace intervalset => ../intervalset
+
+replace inventory => ../inventory
@@ -1,66 +1,292 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"intervalset"
+	"inventory"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-type Range struct {
-	start int
-	end   int
+func getRanges(input []string) []inventory.Range {
+	return inventory.MustParse(input).Ranges
 }
 
-func (r *Range) Contains(value int) bool {
-	return value >= r.start && value <= r.end
+func getIds(input []string) []int {
+	return inventory.MustParse(input).Ids
+}
+
+func getFreshSet(ranges []inventory.Range) *intervalset.IntervalSet {
+	intervals := []intervalset.Interval{}
+	for _, r := range ranges {
+		intervals = append(intervals, intervalset.Interval{Start: r.Start, End: r.End})
+	}
+	return intervalset.New(intervals...)
 }
 
-func getRanges(input []string) []Range {
-	var ranges []Range
-	for _, line := range input {
-		if line == "" {
-			break
+func run(input []string) int {
+	fresh := getFreshSet(getRanges(input))
+	ids := getIds(input)
+
+	count := 0
+	for _, id := range ids {
+		if fresh.Contains(id) {
+			count++
 		}
-		var start, end int
-		fmt.Sscanf(line, "%d-%d", &start, &end)
-		ranges = append(ranges, Range{start: start, end: end})
 	}
-	return ranges
+
+	return count
 }
 
-func getIds(input []string) []int {
-	var ids []int
-	readingIds := false
-	for _, line := range input {
-		if line == "" {
-			readingIds = true
+// segments split the number line at every range boundary,
+// so each segment is covered by the same ranges from start to end
+type segment struct {
+	interval intervalset.Interval
+	covering []inventory.Range
+}
+
+type FreshIndex struct {
+	fresh    *intervalset.IntervalSet
+	segments []segment
+}
+
+func newFreshIndex(ranges []inventory.Range) *FreshIndex {
+	starts := map[int][]inventory.Range{}
+	ends := map[int][]inventory.Range{}
+	boundaries := map[int]int{}
+	for _, r := range ranges {
+		starts[r.Start] = append(starts[r.Start], r)
+		ends[r.End+1] = append(ends[r.End+1], r)
+		boundaries[r.Start]++
+		boundaries[r.End+1]--
+	}
+
+	keys := []int{}
+	for k := range boundaries {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	segments := []segment{}
+	active := map[inventory.Range]int{}
+	for ind, key := range keys {
+		for _, r := range ends[key] {
+			active[r]--
+			if active[r] == 0 {
+				delete(active, r)
+			}
+		}
+		for _, r := range starts[key] {
+			active[r]++
+		}
+		if len(active) == 0 || ind == len(keys)-1 {
 			continue
 		}
-		if readingIds {
-			var id int
-			fmt.Sscanf(line, "%d", &id)
-			ids = append(ids, id)
+
+		covering := []inventory.Range{}
+		for r, count := range active {
+			for ; count > 0; count-- {
+				covering = append(covering, r)
+			}
 		}
+		sort.Slice(covering, func(i, j int) bool {
+			if covering[i].Start != covering[j].Start {
+				return covering[i].Start < covering[j].Start
+			}
+			return covering[i].End < covering[j].End
+		})
+		segments = append(segments, segment{
+			interval: intervalset.Interval{Start: key, End: keys[ind+1] - 1},
+			covering: covering,
+		})
 	}
-	return ids
+
+	return &FreshIndex{fresh: getFreshSet(ranges), segments: segments}
 }
 
-func run(input []string) int {
-	ranges := getRanges(input)
-	ids := getIds(input)
+func (f *FreshIndex) IsFresh(id int) bool {
+	return f.fresh.Contains(id)
+}
 
-	count := 0
-	for _, id := range ids {
-		for _, r := range ranges {
-			if r.Contains(id) {
-				count++
-				break
+func (f *FreshIndex) Covering(id int) []inventory.Range {
+	ind := sort.Search(len(f.segments), func(i int) bool {
+		return f.segments[i].interval.End >= id
+	})
+	if ind < len(f.segments) && f.segments[ind].interval.Contains(id) {
+		return f.segments[ind].covering
+	}
+	return []inventory.Range{}
+}
+
+func formatRanges(ranges []inventory.Range) []string {
+	formatted := []string{}
+	for _, r := range ranges {
+		formatted = append(formatted, fmt.Sprintf("%d-%d", r.Start, r.End))
+	}
+	return formatted
+}
+
+// FreshService answers queries from the range section of a day5 file
+// and reloads it when the file changes
+type FreshService struct {
+	mu       sync.RWMutex
+	filename string
+	lenient  bool
+	index    *FreshIndex
+	modTime  time.Time
+	size     int64
+}
+
+func loadFreshIndex(filename string, lenient bool) (*FreshIndex, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	lines := strings.Split(string(data), "\n")
+	db, problems := inventory.Parse(lines, lenient)
+	if len(problems) > 0 && !lenient {
+		return nil, problems[0]
+	}
+	return newFreshIndex(db.Ranges), nil
+}
+
+// newFreshService parses the file the same way as the rest of day5a, lenient repairs what it can
+func newFreshService(filename string, lenient bool) (*FreshService, error) {
+	s := &FreshService{filename: filename, lenient: lenient}
+	_, err := s.reloadIfChanged()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FreshService) reloadIfChanged() (bool, error) {
+	info, err := os.Stat(s.filename)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.RLock()
+	unchanged := s.index != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size
+	s.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	index, err := loadFreshIndex(s.filename, s.lenient)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	s.index = index
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+	s.mu.Unlock()
+	return true, nil
+}
+
+// watch polls the file until stop is closed; a failed reload keeps the previous index.
+// It reports on stderr so that stdout stays free for the line protocol.
+func (s *FreshService) watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			reloaded, err := s.reloadIfChanged()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Reload failed: %v\n", err)
+			} else if reloaded {
+				fmt.Fprintf(os.Stderr, "Reloaded %s\n", s.filename)
 			}
 		}
 	}
+}
 
-	return count
+func (s *FreshService) getIndex() *FreshIndex {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index
+}
+
+func writeJson(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func (s *FreshService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/fresh" && r.URL.Path != "/ranges" {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/fresh":
+		writeJson(w, map[string]any{"id": id, "fresh": s.getIndex().IsFresh(id)})
+	case "/ranges":
+		writeJson(w, map[string]any{"id": id, "ranges": formatRanges(s.getIndex().Covering(id))})
+	}
+}
+
+// serveLines answers "fresh <id>" and "ranges <id>" queries, one per line
+func (s *FreshService) serveLines(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var id int
+		var err error
+		if len(fields) == 2 {
+			id, err = strconv.Atoi(fields[1])
+		}
+		if len(fields) != 2 || err != nil {
+			fmt.Fprintf(w, "error: expected \"fresh <id>\" or \"ranges <id>\"\n")
+			continue
+		}
+
+		switch fields[0] {
+		case "fresh":
+			if s.getIndex().IsFresh(id) {
+				fmt.Fprintf(w, "%d fresh\n", id)
+			} else {
+				fmt.Fprintf(w, "%d spoiled\n", id)
+			}
+		case "ranges":
+			ranges := formatRanges(s.getIndex().Covering(id))
+			if len(ranges) == 0 {
+				fmt.Fprintf(w, "%d none\n", id)
+			} else {
+				fmt.Fprintf(w, "%d %s\n", id, strings.Join(ranges, " "))
+			}
+		default:
+			fmt.Fprintf(w, "error: unknown query %q\n", fields[0])
+		}
+	}
+	return scanner.Err()
 }
 
 func test(input []string, exp_output int) bool {
@@ -78,6 +304,155 @@ func test(input []string, exp_output int) bool {
 	}
 }
 
+func testCovering(input []string, id int, exp_ranges []string) bool {
+
+	ranges := formatRanges(newFreshIndex(getRanges(input)).Covering(id))
+
+	if fmt.Sprint(ranges) == fmt.Sprint(exp_ranges) {
+		fmt.Printf("✅Test passed: %v %d\n", input, id)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v %d\n", input, id)
+		fmt.Printf("Actual ranges: %v\n", ranges)
+		fmt.Printf("Expected ranges: %v\n", exp_ranges)
+		return false
+	}
+}
+
+func writeTestFile(filename string, input []string, modTime time.Time) {
+	err := os.WriteFile(filename, []byte(strings.Join(input, "\n")), 0644)
+	if err != nil {
+		panic(err)
+	}
+	err = os.Chtimes(filename, modTime, modTime)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func testHttp(handler http.Handler, target string, exp_status int, exp_body string) bool {
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	body := strings.TrimSpace(recorder.Body.String())
+
+	if recorder.Code == exp_status && body == exp_body {
+		fmt.Printf("✅Test passed: %v\n", target)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", target)
+		fmt.Printf("Actual response: %d %s\n", recorder.Code, body)
+		fmt.Printf("Expected response: %d %s\n", exp_status, exp_body)
+		return false
+	}
+}
+
+func testLines(service *FreshService, queries string, exp_output string) bool {
+
+	var output bytes.Buffer
+	err := service.serveLines(strings.NewReader(queries), &output)
+
+	if err == nil && output.String() == exp_output {
+		fmt.Printf("✅Test passed: %q\n", queries)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %q\n", queries)
+		fmt.Printf("Actual output: %q %v\n", output.String(), err)
+		fmt.Printf("Expected output: %q\n", exp_output)
+		return false
+	}
+}
+
+func testService() bool {
+	success := true
+
+	dir, err := os.MkdirTemp("", "day5a")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := dir + "/ranges.txt"
+	modTime := time.Now().Add(-time.Hour)
+	writeTestFile(filename, []string{"3-5", "10-14", "16-20", "12-18", "", "1", "5"}, modTime)
+
+	service, err := newFreshService(filename, false)
+	if err != nil {
+		panic(err)
+	}
+
+	success = testHttp(service, "/fresh?id=5", http.StatusOK, `{"fresh":true,"id":5}`) && success
+	success = testHttp(service, "/fresh?id=8", http.StatusOK, `{"fresh":false,"id":8}`) && success
+	success = testHttp(service, "/ranges?id=13", http.StatusOK, `{"id":13,"ranges":["10-14","12-18"]}`) && success
+	success = testHttp(service, "/ranges?id=32", http.StatusOK, `{"id":32,"ranges":[]}`) && success
+	success = testHttp(service, "/fresh?id=abc", http.StatusBadRequest, "invalid id") && success
+	success = testHttp(service, "/spoiled?id=1", http.StatusNotFound, "404 page not found") && success
+	success = testHttp(service, "/spoiled", http.StatusNotFound, "404 page not found") && success
+	success = testHttp(service, "/fresh", http.StatusBadRequest, "invalid id") && success
+
+	success = testLines(service, "fresh 5\nfresh 8\n\nranges 17\nranges 9\nfresh\nstale 1\n", ""+
+		"5 fresh\n"+
+		"8 spoiled\n"+
+		"17 12-18 16-20\n"+
+		"9 none\n"+
+		"error: expected \"fresh <id>\" or \"ranges <id>\"\n"+
+		"error: unknown query \"stale\"\n") && success
+
+	reloaded, err := service.reloadIfChanged()
+	if err != nil || reloaded {
+		fmt.Printf("❌Test failed: reload of an unchanged file\n")
+		success = false
+	}
+
+	writeTestFile(filename, []string{"6-9"}, modTime.Add(time.Minute))
+	reloaded, err = service.reloadIfChanged()
+	if err != nil || !reloaded {
+		fmt.Printf("❌Test failed: reload of a changed file\n")
+		success = false
+	}
+	success = testHttp(service, "/fresh?id=5", http.StatusOK, `{"fresh":false,"id":5}`) && success
+	success = testHttp(service, "/ranges?id=8", http.StatusOK, `{"id":8,"ranges":["6-9"]}`) && success
+
+	repairable := dir + "/repairable.txt"
+	writeTestFile(repairable, []string{"5-3", "3-x", "10-12", "", "4"}, modTime)
+	_, err = newFreshService(repairable, false)
+	if err == nil || err.Error() != `line 1: reversed range "5-3"` {
+		fmt.Printf("❌Test failed: strict service on a repairable file\n")
+		fmt.Printf("Actual error: %v\n", err)
+		success = false
+	}
+	lenientService, err := newFreshService(repairable, true)
+	if err != nil {
+		fmt.Printf("❌Test failed: lenient service on a repairable file\n")
+		fmt.Printf("Actual error: %v\n", err)
+		return false
+	}
+	success = testHttp(lenientService, "/fresh?id=4", http.StatusOK, `{"fresh":true,"id":4}`) && success
+	success = testHttp(lenientService, "/ranges?id=11", http.StatusOK, `{"id":11,"ranges":["10-12"]}`) && success
+
+	return success
+}
+
+func testParse(input []string, lenient bool, exp_db string, exp_problems []string) bool {
+
+	db, problems := inventory.Parse(input, lenient)
+	dbString := fmt.Sprintf("%v %v", db.Ranges, db.Ids)
+	problemStrings := []string{}
+	for _, p := range problems {
+		problemStrings = append(problemStrings, p.Error())
+	}
+
+	if dbString == exp_db && fmt.Sprintf("%q", problemStrings) == fmt.Sprintf("%q", exp_problems) {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual: %s %q\n", dbString, problemStrings)
+		fmt.Printf("Expected: %s %q\n", exp_db, exp_problems)
+		return false
+	}
+}
+
 func readInput(filename string) []string {
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -89,6 +464,11 @@ func readInput(filename string) []string {
 }
 
 func main() {
+	lenient := flag.Bool("lenient", false, "repair what can be repaired in the input instead of failing")
+	serve := flag.String("serve", "", "answer freshness queries over HTTP on this address, e.g. localhost:8080")
+	stdin := flag.Bool("stdin", false, "answer freshness queries read from stdin")
+	flag.Parse()
+
 	success := true
 
 	success = test([]string{"3-5", "", "1"}, 0) && success
@@ -99,6 +479,8 @@ func main() {
 	success = test([]string{"3-5", "7-9", "", "6"}, 0) && success
 	success = test([]string{"3-5", "7-9", "", "8"}, 1) && success
 	success = test([]string{"3-5", "7-9", "8-9", "", "8"}, 1) && success
+	success = test([]string{"7-9", "3-5", "4-8", "", "2", "6", "10"}, 1) && success
+	success = test([]string{"3-5", "6-8", "", "5", "6", "9"}, 2) && success
 
 	success = test([]string{
 		"3-5",
@@ -114,6 +496,25 @@ func main() {
 		"32",
 	}, 3) && success
 
+	success = testCovering([]string{"3-5", "10-14", "16-20", "12-18"}, 2, []string{}) && success
+	success = testCovering([]string{"3-5", "10-14", "16-20", "12-18"}, 3, []string{"3-5"}) && success
+	success = testCovering([]string{"3-5", "10-14", "16-20", "12-18"}, 14, []string{"10-14", "12-18"}) && success
+	success = testCovering([]string{"3-5", "10-14", "16-20", "12-18"}, 15, []string{"12-18"}) && success
+	success = testCovering([]string{"3-5", "10-14", "16-20", "12-18"}, 21, []string{}) && success
+	success = testCovering([]string{"3-5", "3-5", "4-4"}, 4, []string{"3-5", "3-5", "4-4"}) && success
+
+	success = testService() && success
+
+	success = testParse([]string{"3-5", "", "1", ""}, false, "[{3 5}] [1]", []string{}) && success
+	success = testParse([]string{" 3 - 5 ", "", " 1 "}, false, "[{3 5}] [1]", []string{}) && success
+	success = testParse([]string{"3-x", "7-9", "", "1"}, false, "[{7 9}] [1]", []string{`line 1: invalid range "3-x"`}) && success
+	success = testParse([]string{"3-x", "7-9", "", "1"}, true, "[{7 9}] [1]", []string{`line 1: invalid range "3-x", skipped`}) && success
+	success = testParse([]string{"3-5", "", "1", "abc", "6-7"}, false, "[{3 5}] [1]", []string{`line 4: invalid ID "abc"`, `line 5: invalid ID "6-7"`}) && success
+	success = testParse([]string{"5-3", "", "4"}, false, "[{3 5}] [4]", []string{`line 1: reversed range "5-3"`}) && success
+	success = testParse([]string{"5-3", "", "4"}, true, "[{3 5}] [4]", []string{`line 1: reversed range "5-3", swapped to 3-5`}) && success
+	success = testParse([]string{"3-5", "7", "9"}, false, "[{3 5}] [7 9]", []string{"line 2: missing blank line before IDs"}) && success
+	success = testParse([]string{"3-5", "7", "9"}, true, "[{3 5}] [7 9]", []string{"line 2: missing blank line before IDs, assumed here"}) && success
+
 	if success {
 		fmt.Printf("-=-=-=-=-=-=-=-=-=-=-=-=-\n✅All tests passed!\n")
 	} else {
@@ -122,7 +523,34 @@ func main() {
 	}
 
 	input := readInput("input.txt")
+	if *lenient {
+		db, problems := inventory.Parse(input, true)
+		for _, p := range problems {
+			fmt.Printf("Warning: %v\n", p)
+		}
+		input = inventory.Format(db)
+	}
 
 	result := run(input)
 	fmt.Printf("Result: %d\n", result)
+
+	if *serve == "" && !*stdin {
+		return
+	}
+
+	service, err := newFreshService("input.txt", *lenient)
+	if err != nil {
+		panic(err)
+	}
+	go service.watch(time.Second, nil)
+
+	if *stdin {
+		err = service.serveLines(os.Stdin, os.Stdout)
+	} else {
+		fmt.Printf("Serving on %s\n", *serve)
+		err = http.ListenAndServe(*serve, service)
+	}
+	if err != nil {
+		panic(err)
+	}
 }
@@ -2,35 +2,38 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
+	"intervalset"
+	"inventory"
 	"os"
 	"sort"
 	"strings"
 )
 
-type Range struct {
-	start int
-	end   int
+func getRanges(input []string) []inventory.Range {
+	return inventory.MustParse(input).Ranges
 }
 
-func getRanges(input []string) []Range {
-	var ranges []Range
-	for _, line := range input {
-		if line == "" {
-			break
-		}
-		var start, end int
-		fmt.Sscanf(line, "%d-%d", &start, &end)
-		ranges = append(ranges, Range{start: start, end: end})
+// repairRanges parses the input leniently and writes it back without the problems it repaired
+func repairRanges(input []string) ([]string, []inventory.ParseError) {
+	db, problems := inventory.Parse(input, true)
+	return inventory.Format(db), problems
+}
+
+func getFreshSet(ranges []inventory.Range) *intervalset.IntervalSet {
+	intervals := []intervalset.Interval{}
+	for _, r := range ranges {
+		intervals = append(intervals, intervalset.Interval{Start: r.Start, End: r.End})
 	}
-	return ranges
+	return intervalset.New(intervals...)
 }
 
-func getSwitches(ranges []Range) map[int]int {
+func getSwitches(ranges []inventory.Range) map[int]int {
 	switches := make(map[int]int)
 	for _, r := range ranges {
-		switches[r.start]++
-		switches[r.end+1]--
+		switches[r.Start]++
+		switches[r.End+1]--
 	}
 	return switches
 }
@@ -46,33 +49,123 @@ func getSortedKeys(m map[int]int) []int {
 	return keys
 }
 
-func run(input []string) int {
-	ranges := getRanges(input)
+type DepthReport struct {
+	histogram    []int // histogram[d-1] is the number of IDs covered by exactly d ranges
+	maxDepth     int
+	maxIntervals *intervalset.IntervalSet
+}
+
+func getDepthReport(ranges []inventory.Range) DepthReport {
 	switches := getSwitches(ranges)
 	sortedKeys := getSortedKeys(switches)
 
-	count := 0
+	report := DepthReport{histogram: []int{}}
+	maxIntervals := []intervalset.Interval{}
 	curSwitch := 0 // current number of active ranges
-	prevKey := sortedKeys[0]
-	for _, key := range sortedKeys {
-		switch_of_current_key := switches[key]
+	for ind, key := range sortedKeys {
+		curSwitch += switches[key]
 
-		if curSwitch == 0 && switch_of_current_key > 0 {
-			prevKey = key
+		if curSwitch < 0 {
+			panic("curSwitch < 0")
 		}
 
-		curSwitch += switch_of_current_key
+		if curSwitch == 0 || ind == len(sortedKeys)-1 {
+			continue
+		}
 
-		if curSwitch < 0 {
+		// every ID in [key, nextKey) is covered by curSwitch ranges
+		nextKey := sortedKeys[ind+1]
+		for len(report.histogram) < curSwitch {
+			report.histogram = append(report.histogram, 0)
+		}
+		report.histogram[curSwitch-1] += nextKey - key
+
+		if curSwitch > report.maxDepth {
+			report.maxDepth = curSwitch
+			maxIntervals = []intervalset.Interval{}
+		}
+		if curSwitch == report.maxDepth {
+			maxIntervals = append(maxIntervals, intervalset.Interval{Start: key, End: nextKey - 1})
+		}
+	}
+	report.maxIntervals = intervalset.New(maxIntervals...)
+
+	return report
+}
+
+func formatDepthReport(report DepthReport) string {
+	var sb strings.Builder
+	for ind, count := range report.histogram {
+		sb.WriteString(fmt.Sprintf("Depth %d: %d\n", ind+1, count))
+	}
+	sb.WriteString(fmt.Sprintf("Max depth: %d\n", report.maxDepth))
+	sb.WriteString(fmt.Sprintf("Max depth intervals: %v\n", report.maxIntervals))
+	return sb.String()
+}
+
+type RangesDiff struct {
+	added     *intervalset.IntervalSet
+	removed   *intervalset.IntervalSet
+	unchanged *intervalset.IntervalSet
+}
+
+func diffRanges(oldRanges, newRanges []inventory.Range) RangesDiff {
+	oldSwitches := getSwitches(oldRanges)
+	newSwitches := getSwitches(newRanges)
+
+	allSwitches := make(map[int]int)
+	for key := range oldSwitches {
+		allSwitches[key] = 0
+	}
+	for key := range newSwitches {
+		allSwitches[key] = 0
+	}
+	sortedKeys := getSortedKeys(allSwitches)
+
+	added := []intervalset.Interval{}
+	removed := []intervalset.Interval{}
+	unchanged := []intervalset.Interval{}
+	oldSwitch := 0 // current number of active old ranges
+	newSwitch := 0 // current number of active new ranges
+	for ind, key := range sortedKeys {
+		oldSwitch += oldSwitches[key]
+		newSwitch += newSwitches[key]
+
+		if oldSwitch < 0 || newSwitch < 0 {
 			panic("curSwitch < 0")
 		}
 
-		if curSwitch == 0 {
-			count += key - prevKey
+		if ind == len(sortedKeys)-1 {
+			continue
+		}
+
+		interval := intervalset.Interval{Start: key, End: sortedKeys[ind+1] - 1}
+		if oldSwitch > 0 && newSwitch > 0 {
+			unchanged = append(unchanged, interval)
+		} else if oldSwitch > 0 {
+			removed = append(removed, interval)
+		} else if newSwitch > 0 {
+			added = append(added, interval)
 		}
 	}
 
-	return count
+	return RangesDiff{
+		added:     intervalset.New(added...),
+		removed:   intervalset.New(removed...),
+		unchanged: intervalset.New(unchanged...),
+	}
+}
+
+func formatRangesDiff(diff RangesDiff) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Newly covered: %v (total %d)\n", diff.added, diff.added.Len()))
+	sb.WriteString(fmt.Sprintf("No longer covered: %v (total %d)\n", diff.removed, diff.removed.Len()))
+	sb.WriteString(fmt.Sprintf("Unchanged: %v (total %d)\n", diff.unchanged, diff.unchanged.Len()))
+	return sb.String()
+}
+
+func run(input []string) int {
+	return getFreshSet(getRanges(input)).Len()
 }
 
 func test(input []string, exp_output int) bool {
@@ -90,6 +183,53 @@ func test(input []string, exp_output int) bool {
 	}
 }
 
+func testSet(set *intervalset.IntervalSet, exp_set string) bool {
+
+	if set.String() == exp_set {
+		fmt.Printf("✅Test passed: %v\n", exp_set)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", exp_set)
+		fmt.Printf("Actual set: %v\n", set)
+		fmt.Printf("Expected set: %v\n", exp_set)
+		return false
+	}
+}
+
+func testDepthReport(input []string, exp_report string) bool {
+
+	report := formatDepthReport(getDepthReport(getRanges(input)))
+
+	if report == exp_report {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual report:\n%s", report)
+		fmt.Printf("Expected report:\n%s", exp_report)
+		return false
+	}
+}
+
+func testDiff(oldInput, newInput []string, lenient bool, exp_diff string) bool {
+
+	if lenient {
+		oldInput, _ = repairRanges(oldInput)
+		newInput, _ = repairRanges(newInput)
+	}
+	diff := formatRangesDiff(diffRanges(getRanges(oldInput), getRanges(newInput)))
+
+	if diff == exp_diff {
+		fmt.Printf("✅Test passed: %v -> %v\n", oldInput, newInput)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v -> %v\n", oldInput, newInput)
+		fmt.Printf("Actual diff:\n%s", diff)
+		fmt.Printf("Expected diff:\n%s", exp_diff)
+		return false
+	}
+}
+
 func readInput(filename string) []string {
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -101,6 +241,10 @@ func readInput(filename string) []string {
 }
 
 func main() {
+	lenient := flag.Bool("lenient", false, "repair what can be repaired in the input instead of failing")
+	diff := flag.Bool("diff", false, "compare two range files given as arguments: old.txt new.txt")
+	flag.Parse()
+
 	success := true
 
 	success = test([]string{"3-5"}, 3) && success
@@ -114,6 +258,53 @@ func main() {
 		"12-18",
 	}, 14) && success
 
+	success = test([]string{}, 0) && success
+	success = test([]string{"3-5", "6-8"}, 6) && success
+	success = test([]string{"10-14", "3-5", "4-12"}, 12) && success
+
+	success = testDepthReport([]string{}, "Max depth: 0\nMax depth intervals: []\n") && success
+	success = testDepthReport([]string{"3-5"}, "Depth 1: 3\nMax depth: 1\nMax depth intervals: [3-5]\n") && success
+	success = testDepthReport([]string{"3-5", "3-5", "5-7"}, "Depth 1: 2\nDepth 2: 2\nDepth 3: 1\nMax depth: 3\nMax depth intervals: [5-5]\n") && success
+	success = testDepthReport([]string{"1-4", "3-6", "6-9"}, "Depth 1: 6\nDepth 2: 3\nMax depth: 2\nMax depth intervals: [3-4 6-6]\n") && success
+	success = testDepthReport([]string{
+		"3-5",
+		"10-14",
+		"16-20",
+		"12-18",
+	}, "Depth 1: 8\nDepth 2: 6\nMax depth: 2\nMax depth intervals: [12-14 16-18]\n") && success
+
+	success = testDiff([]string{}, []string{}, false, ""+
+		"Newly covered: [] (total 0)\n"+
+		"No longer covered: [] (total 0)\n"+
+		"Unchanged: [] (total 0)\n") && success
+	success = testDiff([]string{"3-5"}, []string{"3-5"}, false, ""+
+		"Newly covered: [] (total 0)\n"+
+		"No longer covered: [] (total 0)\n"+
+		"Unchanged: [3-5] (total 3)\n") && success
+	success = testDiff([]string{"3-5", "10-14"}, []string{"4-8", "14-14", "20-21"}, false, ""+
+		"Newly covered: [6-8 20-21] (total 5)\n"+
+		"No longer covered: [3-3 10-13] (total 5)\n"+
+		"Unchanged: [4-5 14-14] (total 3)\n") && success
+	success = testDiff([]string{"3-5", "10-14", "16-20", "12-18"}, []string{"3-20"}, false, ""+
+		"Newly covered: [6-9] (total 4)\n"+
+		"No longer covered: [] (total 0)\n"+
+		"Unchanged: [3-5 10-20] (total 14)\n") && success
+	success = testDiff([]string{"5-3", "10-14"}, []string{"4-8", "", "7"}, true, ""+
+		"Newly covered: [6-8] (total 3)\n"+
+		"No longer covered: [3-3 10-14] (total 6)\n"+
+		"Unchanged: [4-5] (total 2)\n") && success
+
+	a := intervalset.New(intervalset.Interval{Start: 3, End: 5}, intervalset.Interval{Start: 10, End: 14})
+	b := intervalset.New(intervalset.Interval{Start: 5, End: 11}, intervalset.Interval{Start: 14, End: 20})
+	success = testSet(intervalset.New(intervalset.Interval{Start: 6, End: 8}, intervalset.Interval{Start: 3, End: 5}, intervalset.Interval{Start: 9, End: 8}), "[3-8]") && success
+	success = testSet(a.Union(b), "[3-20]") && success
+	success = testSet(a.Intersect(b), "[5-5 10-11 14-14]") && success
+	success = testSet(a.Difference(b), "[3-4 12-13]") && success
+	success = testSet(b.Difference(a), "[6-9 15-20]") && success
+	success = testSet(a.Complement(intervalset.Interval{Start: 0, End: 12}), "[0-2 6-9]") && success
+	success = testSet(a.Complement(intervalset.Interval{Start: 4, End: 4}), "[]") && success
+	success = testSet(intervalset.New().Complement(intervalset.Interval{Start: 1, End: 2}), "[1-2]") && success
+
 	if success {
 		fmt.Printf("-=-=-=-=-=-=-=-=-=-=-=-=-\n✅All tests passed!\n")
 	} else {
@@ -121,8 +312,32 @@ func main() {
 		return
 	}
 
-	input := readInput("input.txt")
+	loadInput := func(filename string) []string {
+		input := readInput(filename)
+		if !*lenient {
+			return input
+		}
+		input, problems := repairRanges(input)
+		for _, p := range problems {
+			fmt.Printf("Warning: %s: %v\n", filename, p)
+		}
+		return input
+	}
+
+	if *diff {
+		if flag.NArg() != 2 {
+			panic("Expected two range files: old.txt new.txt")
+		}
+		oldInput := loadInput(flag.Arg(0))
+		newInput := loadInput(flag.Arg(1))
+		fmt.Print(formatRangesDiff(diffRanges(getRanges(oldInput), getRanges(newInput))))
+		return
+	}
+
+	input := loadInput("input.txt")
 
 	result := run(input)
 	fmt.Printf("Result: %d\n", result)
+
+	fmt.Print(formatDepthReport(getDepthReport(getRanges(input))))
 }
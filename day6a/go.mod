@@ -1,3 +1,7 @@
 module day6a
 
 go 1.25.4
+
+require worksheet v0.0.0
+
+replace worksheet => ../worksheet
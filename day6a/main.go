@@ -2,69 +2,146 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"worksheet"
 )
 
-func readLinesNumbers(input []string) [][]int {
-	lines := make([][]int, len(input)-1)
-	for i, line := range input {
-		if i == len(input)-1 {
-			break
-		}
-		parts := strings.Fields(line)
-		nums := make([]int, len(parts))
-		for j, part := range parts {
-			var n int
-			fmt.Sscanf(part, "%d", &n)
-			nums[j] = n
-		}
-		lines[i] = nums
+func evaluate(input []string, alwaysBig bool) (worksheet.Number, error) {
+	problems, err := worksheet.Parse(input, worksheet.RowMajor)
+	if err != nil {
+		return worksheet.Number{}, err
 	}
-	return lines
+	return worksheet.Evaluate(problems, alwaysBig)
 }
 
-func readLinesOperations(input []string) []string {
-	parts := strings.Fields(input[len(input)-1])
-	return parts
+func run(input []string, alwaysBig bool) worksheet.Number {
+	total, err := evaluate(input, alwaysBig)
+	if err != nil {
+		panic(err)
+	}
+	return total
 }
 
-func run(input []string) int {
-	numbers := readLinesNumbers(input)
-	operations := readLinesOperations(input)
+func test(input []string, exp_output int) bool {
+
+	output := run(input, false)
+	outputBig := run(input, true)
 
-	total := 0
-	for col, ops := range operations {
+	if !output.IsBig() && output.Int() == exp_output && outputBig.String() == strconv.Itoa(exp_output) {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual output: %v (big: %v)\n", output, outputBig)
+		fmt.Printf("Expected output: %d\n", exp_output)
+		return false
+	}
+}
 
-		lineSum := numbers[0][col]
+func testBig(input []string, exp_output string) bool {
 
-		for row := 1; row < len(numbers); row++ {
-			switch ops {
-			case "+":
-				lineSum += numbers[row][col]
-			case "*":
-				lineSum *= numbers[row][col]
-			}
-		}
+	output := run(input, false)
+	outputBig := run(input, true)
 
-		total += lineSum
+	if output.String() == exp_output && outputBig.String() == exp_output {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual output: %v (big: %v)\n", output, outputBig)
+		fmt.Printf("Expected output: %s\n", exp_output)
+		return false
 	}
+}
 
-	return total
+func testError(input []string, exp_error string) bool {
+
+	_, err := evaluate(input, false)
+
+	if err != nil && err.Error() == exp_error {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual error: %v\n", err)
+		fmt.Printf("Expected error: %v\n", exp_error)
+		return false
+	}
 }
 
-func test(input []string, exp_output int) bool {
+func testWarnings(input []string, opts worksheet.Options, exp_warnings []string) bool {
+
+	_, warnings, err := worksheet.ParseTolerant(input, opts)
+	if err != nil {
+		panic(err)
+	}
+	warningStrings := []string{}
+	for _, w := range warnings {
+		warningStrings = append(warningStrings, w.String())
+	}
+
+	if fmt.Sprintf("%q", warningStrings) == fmt.Sprintf("%q", exp_warnings) {
+		fmt.Printf("✅Test passed: %q\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %q\n", input)
+		fmt.Printf("Actual warnings: %q\n", warningStrings)
+		fmt.Printf("Expected warnings: %q\n", exp_warnings)
+		return false
+	}
+}
 
-	output := run(input)
+func testExplain(input []string, exp_lines []string) bool {
 
-	if output == exp_output {
+	problems, err := worksheet.Parse(input, worksheet.RowMajor)
+	if err != nil {
+		panic(err)
+	}
+	lines, err := worksheet.Explain(problems, false)
+	if err != nil {
+		panic(err)
+	}
+
+	if fmt.Sprintf("%q", lines) == fmt.Sprintf("%q", exp_lines) {
 		fmt.Printf("✅Test passed: %v\n", input)
 		return true
 	} else {
 		fmt.Printf("❌Test failed: %v\n", input)
-		fmt.Printf("Actual output: %d\n", output)
-		fmt.Printf("Expected output: %d\n", exp_output)
+		fmt.Printf("Actual lines: %q\n", lines)
+		fmt.Printf("Expected lines: %q\n", exp_lines)
+		return false
+	}
+}
+
+func testRender(input []string, order worksheet.ReadingOrder, exp_rendered []string) bool {
+
+	problems, err := worksheet.Parse(input, worksheet.RowMajor)
+	if err != nil {
+		panic(err)
+	}
+	rendered := worksheet.Render(problems, order)
+	reparsed, err := worksheet.Parse(rendered, order)
+	if err != nil {
+		panic(err)
+	}
+
+	sameProblems := len(problems) == len(reparsed)
+	for ind := 0; sameProblems && ind < len(problems); ind++ {
+		sameProblems = problems[ind].Operator == reparsed[ind].Operator &&
+			fmt.Sprint(problems[ind].Numbers) == fmt.Sprint(reparsed[ind].Numbers)
+	}
+
+	if sameProblems && fmt.Sprintf("%q", rendered) == fmt.Sprintf("%q", exp_rendered) {
+		fmt.Printf("✅Test passed: %v %+v\n", input, order)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v %+v\n", input, order)
+		fmt.Printf("Actual rendered: %q\n", rendered)
+		fmt.Printf("Expected rendered: %q\n", exp_rendered)
 		return false
 	}
 }
@@ -80,6 +157,12 @@ func readInput(filename string) []string {
 }
 
 func main() {
+	alwaysBig := flag.Bool("big", false, "always evaluate with math/big instead of falling back on overflow")
+	tabWidth := flag.Int("tab", 8, "tab width used to align the worksheet columns")
+	explain := flag.Bool("explain", false, "print every problem as an equation")
+	csvFilename := flag.String("csv", "", "export the problems to this CSV file")
+	flag.Parse()
+
 	success := true
 
 	success = test([]string{"2", "3", "4", "*"}, 24) && success
@@ -87,6 +170,77 @@ func main() {
 	success = test([]string{"2 1", "3  2", "4   3", "*  +"}, 30) && success
 	success = test([]string{"123 328  51 64 ", " 45 64  387 23 ", "  6 98  215 314", "*   +   *   +  "}, 4277556) && success
 
+	success = test([]string{"20 7", "3  2", "-  %"}, 18) && success
+	success = test([]string{"20 7", "3  2", "/  ^"}, 55) && success
+	success = test([]string{"2   5   12  4", "8   3   18  6", "min max gcd lcm"}, 25) && success
+	success = test([]string{"100", "10", "2", "-"}, 88) && success
+	success = test([]string{"100", "10", "2", "/"}, 5) && success
+	success = test([]string{"2", "3", "2", "^"}, 64) && success
+	success = test([]string{"1", "1000000000000000000", "^"}, 1) && success
+	success = test([]string{"0", "1000000000000000000", "^"}, 0) && success
+	success = test([]string{"-1", "1000000000000000001", "^"}, -1) && success
+	success = test([]string{"3", "39", "^"}, 4052555153018976267) && success
+	success = test([]string{"-2", "63", "^"}, -9223372036854775808) && success
+	success = test([]string{"7", "0", "^"}, 1) && success
+
+	success = testBig([]string{"4294967296", "4294967296", "*"}, "18446744073709551616") && success
+	success = testBig([]string{"9223372036854775807", "1", "+"}, "9223372036854775808") && success
+	success = testBig([]string{"9223372036854775807 9223372036854775807", "1                   0", "*                   +"}, "18446744073709551614") && success
+	success = testBig([]string{"-9223372036854775807", "2", "-"}, "-9223372036854775809") && success
+	success = testBig([]string{"2", "64", "^"}, "18446744073709551616") && success
+	success = testBig([]string{"99999999999999999999", "1", "+"}, "100000000000000000000") && success
+	success = testBig([]string{"99999999999999999999 2", "99999999999999999999 3", "-                    ^"}, "8") && success
+	success = testBig([]string{"3", "40", "^"}, "12157665459056928801") && success
+	success = testBig([]string{"4294967296", "3", "^"}, "79228162514264337593543950336") && success
+	success = testBig([]string{"4294967296", "4294967295", "lcm"}, "18446744069414584320") && success
+	success = testBig([]string{"99999", "99999", "99999", "99999", "*"}, "99996000059999600001") && success
+
+	success = test([]string{"123 328  51 64", " 45 64  387 23", "  6 98  215 314", "*   +   *   +"}, 4277556) && success
+	success = test([]string{"*   +   *   +", "123 328  51 64", " 45 64  387 23", "  6 98  215 314", ""}, 4277556) && success
+	success = test([]string{"2\t1", "3\t2", "*\t+"}, 9) && success
+
+	success = testWarnings([]string{"2 1", "3  2", "4   3", "*  +"}, worksheet.Options{Order: worksheet.RowMajor}, []string{}) && success
+	success = testWarnings([]string{"1 2", "345", "+  "}, worksheet.Options{Order: worksheet.RowMajor}, []string{
+		`line 1: gap inside number "1 2"`,
+	}) && success
+
+	success = testExplain([]string{"123 328  51 64 ", " 45 64  387 23 ", "  6 98  215 314", "*   +   *   +  "}, []string{
+		"Problem 1, columns 1-3: 123 * 45 * 6 = 33210",
+		"Problem 2, columns 5-7: 328 + 64 + 98 = 490",
+		"Problem 3, columns 9-11: 51 * 387 * 215 = 4243455",
+		"Problem 4, columns 13-15: 64 + 23 + 314 = 401",
+	}) && success
+	success = testExplain([]string{"2   12", "8   18", "min gcd"}, []string{
+		"Problem 1, columns 1-3: min(2, 8) = 2",
+		"Problem 2, columns 5-7: gcd(12, 18) = 6",
+	}) && success
+
+	success = testRender([]string{"123 328  51 64 ", " 45 64  387 23 ", "  6 98  215 314", "*   +   *   +  "}, worksheet.RowMajor, []string{
+		"123 328 51  64 ",
+		"45  64  387 23 ",
+		"6   98  215 314",
+		"*   +   *   +  ",
+	}) && success
+	success = testRender([]string{"123 328  51 64 ", " 45 64  387 23 ", "  6 98  215 314", "*   +   *   +  "}, worksheet.ColumnMajor, []string{
+		"146 369 532 623",
+		"25  248 181 431",
+		"3   8    75   4",
+		"*   +   *   +  ",
+	}) && success
+	success = testRender([]string{"2   12", "8   18", "min gcd"}, worksheet.ReadingOrder{RightToLeft: true, BottomUp: true}, []string{
+		"8   81 ",
+		"2   21 ",
+		"min gcd",
+	}) && success
+
+	success = testError([]string{"2 3", "4 5"}, `no operator row: every line has digits`) && success
+	success = testError([]string{"+", "2", "*"}, `operator row is ambiguous: lines 1 and 3 have no digits`) && success
+	success = testError([]string{"", " "}, `worksheet is empty`) && success
+	success = testError([]string{"2 3", "4 5", "+ ?"}, `unknown operator "?" at column 3`) && success
+	success = testError([]string{"2 3", "4 5", "+ sum"}, `unknown operator "sum" at column 3`) && success
+	success = testError([]string{"2 3", "0 0", "+ /"}, `division by zero at column 3`) && success
+	success = testError([]string{"2 3", "0 0", "% +"}, `modulo by zero at column 1`) && success
+
 	if success {
 		fmt.Printf("-=-=-=-=-=-=-=-=-=-=-=-=-\n✅All tests passed!\n")
 	} else {
@@ -96,6 +250,40 @@ func main() {
 
 	input := readInput("input.txt")
 
-	result := run(input)
-	fmt.Printf("Result: %d\n", result)
+	problems, warnings, err := worksheet.ParseTolerant(input, worksheet.Options{Order: worksheet.RowMajor, TabWidth: *tabWidth})
+	if err != nil {
+		panic(err)
+	}
+	for _, w := range warnings {
+		fmt.Printf("Warning: %v\n", w)
+	}
+
+	if *explain {
+		lines, err := worksheet.Explain(problems, *alwaysBig)
+		if err != nil {
+			panic(err)
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+	}
+
+	if *csvFilename != "" {
+		file, err := os.Create(*csvFilename)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+
+		err = worksheet.WriteCSV(file, problems, *alwaysBig)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	result, err := worksheet.Evaluate(problems, *alwaysBig)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Result: %v\n", result)
 }
@@ -2,101 +2,169 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"worksheet"
 )
 
-func checkInputConsistent(input []string) {
-	if len(input) <= 0 {
-		panic("Input is empty")
-	}
-	numCols := len(input[0])
-	for i, line := range input {
-		if len(line) != numCols {
-			panic(fmt.Sprintf("Inconsistent line length at line %d: expected %d, got %d", i, numCols, len(line)))
-		}
+func evaluate(input []string, alwaysBig bool) (worksheet.Number, error) {
+	problems, err := worksheet.Parse(input, worksheet.ColumnMajor)
+	if err != nil {
+		return worksheet.Number{}, err
 	}
+	return worksheet.Evaluate(problems, alwaysBig)
 }
 
-func isEmptyCol(input []string, col int) bool {
-	for row := 0; row < len(input); row++ {
-		char := input[row][col]
-		if char != ' ' {
-			return false
-		}
+func run(input []string, alwaysBig bool) worksheet.Number {
+	total, err := evaluate(input, alwaysBig)
+	if err != nil {
+		panic(err)
 	}
-	return true
+	return total
 }
 
-func readNumbersFromColumns(input []string) [][]int {
-	numbers := [][]int{}
-	numbers = append(numbers, []int{})
-
-	numCols := len(input[0])
-	for col := 0; col < numCols; col++ {
+func test(input []string, exp_output int) bool {
 
-		if isEmptyCol(input, col) {
-			numbers = append(numbers, []int{})
-			continue
-		}
+	output := run(input, false)
+	outputBig := run(input, true)
 
-		curNumberString := ""
-		for row := 0; row < len(input)-1; row++ {
-			char := input[row][col]
-			curNumberString += string(char)
-		}
-		curNumber := 0
-		fmt.Sscanf(curNumberString, "%d", &curNumber)
-		numbers[len(numbers)-1] = append(numbers[len(numbers)-1], curNumber)
+	if !output.IsBig() && output.Int() == exp_output && outputBig.String() == strconv.Itoa(exp_output) {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual output: %v (big: %v)\n", output, outputBig)
+		fmt.Printf("Expected output: %d\n", exp_output)
+		return false
 	}
-
-	return numbers
 }
 
-func readLinesOperations(input []string) []string {
-	parts := strings.Fields(input[len(input)-1])
-	return parts
+func testBig(input []string, exp_output string) bool {
+
+	output := run(input, false)
+	outputBig := run(input, true)
+
+	if output.String() == exp_output && outputBig.String() == exp_output {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual output: %v (big: %v)\n", output, outputBig)
+		fmt.Printf("Expected output: %s\n", exp_output)
+		return false
+	}
 }
 
-func run(input []string) int {
+func testOrder(input []string, order worksheet.ReadingOrder, exp_output int) bool {
 
-	checkInputConsistent(input)
+	problems, err := worksheet.Parse(input, order)
+	if err != nil {
+		panic(err)
+	}
+	output, err := worksheet.Evaluate(problems, false)
+	if err != nil {
+		panic(err)
+	}
 
-	numbers := readNumbersFromColumns(input)
-	operations := readLinesOperations(input)
+	if !output.IsBig() && output.Int() == exp_output {
+		fmt.Printf("✅Test passed: %v %+v\n", input, order)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v %+v\n", input, order)
+		fmt.Printf("Actual output: %v\n", output)
+		fmt.Printf("Expected output: %d\n", exp_output)
+		return false
+	}
+}
 
-	total := 0
-	for opInd, ops := range operations {
+func testError(input []string, exp_error string) bool {
 
-		lineSum := numbers[opInd][0]
+	_, err := evaluate(input, false)
 
-		for row := 1; row < len(numbers[opInd]); row++ {
-			switch ops {
-			case "+":
-				lineSum += numbers[opInd][row]
-			case "*":
-				lineSum *= numbers[opInd][row]
-			}
-		}
+	if err != nil && err.Error() == exp_error {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual error: %v\n", err)
+		fmt.Printf("Expected error: %v\n", exp_error)
+		return false
+	}
+}
+
+func testWarnings(input []string, opts worksheet.Options, exp_warnings []string) bool {
 
-		total += lineSum
+	_, warnings, err := worksheet.ParseTolerant(input, opts)
+	if err != nil {
+		panic(err)
+	}
+	warningStrings := []string{}
+	for _, w := range warnings {
+		warningStrings = append(warningStrings, w.String())
 	}
 
-	return total
+	if fmt.Sprintf("%q", warningStrings) == fmt.Sprintf("%q", exp_warnings) {
+		fmt.Printf("✅Test passed: %q\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %q\n", input)
+		fmt.Printf("Actual warnings: %q\n", warningStrings)
+		fmt.Printf("Expected warnings: %q\n", exp_warnings)
+		return false
+	}
 }
 
-func test(input []string, exp_output int) bool {
+func testExplain(input []string, exp_lines []string) bool {
 
-	output := run(input)
+	problems, err := worksheet.Parse(input, worksheet.ColumnMajor)
+	if err != nil {
+		panic(err)
+	}
+	lines, err := worksheet.Explain(problems, false)
+	if err != nil {
+		panic(err)
+	}
 
-	if output == exp_output {
+	if fmt.Sprintf("%q", lines) == fmt.Sprintf("%q", exp_lines) {
 		fmt.Printf("✅Test passed: %v\n", input)
 		return true
 	} else {
 		fmt.Printf("❌Test failed: %v\n", input)
-		fmt.Printf("Actual output: %d\n", output)
-		fmt.Printf("Expected output: %d\n", exp_output)
+		fmt.Printf("Actual lines: %q\n", lines)
+		fmt.Printf("Expected lines: %q\n", exp_lines)
+		return false
+	}
+}
+
+func testRender(input []string, order worksheet.ReadingOrder, exp_rendered []string) bool {
+
+	problems, err := worksheet.Parse(input, worksheet.ColumnMajor)
+	if err != nil {
+		panic(err)
+	}
+	rendered := worksheet.Render(problems, order)
+	reparsed, err := worksheet.Parse(rendered, order)
+	if err != nil {
+		panic(err)
+	}
+
+	sameProblems := len(problems) == len(reparsed)
+	for ind := 0; sameProblems && ind < len(problems); ind++ {
+		sameProblems = problems[ind].Operator == reparsed[ind].Operator &&
+			fmt.Sprint(problems[ind].Numbers) == fmt.Sprint(reparsed[ind].Numbers)
+	}
+
+	if sameProblems && fmt.Sprintf("%q", rendered) == fmt.Sprintf("%q", exp_rendered) {
+		fmt.Printf("✅Test passed: %v %+v\n", input, order)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v %+v\n", input, order)
+		fmt.Printf("Actual rendered: %q\n", rendered)
+		fmt.Printf("Expected rendered: %q\n", exp_rendered)
 		return false
 	}
 }
@@ -112,6 +180,12 @@ func readInput(filename string) []string {
 }
 
 func main() {
+	alwaysBig := flag.Bool("big", false, "always evaluate with math/big instead of falling back on overflow")
+	tabWidth := flag.Int("tab", 8, "tab width used to align the worksheet columns")
+	explain := flag.Bool("explain", false, "print every problem as an equation")
+	csvFilename := flag.String("csv", "", "export the problems to this CSV file")
+	flag.Parse()
+
 	success := true
 
 	success = test([]string{"1", "2", "+"}, 12) && success
@@ -119,6 +193,94 @@ func main() {
 	success = test([]string{"13", " 2", "+ "}, 33) && success
 	success = test([]string{"123 328  51 64 ", " 45 64  387 23 ", "  6 98  215 314", "*   +   *   +  "}, 3263827) && success
 
+	success = test([]string{"2 1", "0 2", "- +"}, 32) && success
+	success = test([]string{"95 21", "12  3", "-  ^ "}, 8231) && success
+	success = test([]string{"84 163", "2  448", "/  gcd"}, 22) && success
+	success = test([]string{"93 1", "17 4", "%  +"}, 31) && success
+	success = test([]string{"315 614 462", "527 238 396", "min max lcm"}, 77216) && success
+
+	success = testBig([]string{"9999", "9999", "9999", "9999", "9999", "   *"}, "99996000059999600001") && success
+	success = testBig(append(slices.Repeat([]string{"9"}, 20), "+"), "99999999999999999999") && success
+	success = testBig(append(slices.Repeat([]string{"99"}, 20), "* "), "9999999999999999999800000000000000000001") && success
+	success = testBig(append(slices.Repeat([]string{"91"}, 20), "+ "), "111111111111111111110") && success
+	success = testBig([]string{
+		"9999 9999 9999",
+		"9999 9999 9999",
+		"9999 9999 9999",
+		"9999 9999 9999",
+		"9999 9999 9999",
+		"*    *    +   "}, "199992000119999599998") && success
+
+	success = testOrder([]string{"53", "21", "- "}, worksheet.RowMajor, 32) && success
+	success = testOrder([]string{"53", "21", "- "}, worksheet.ReadingOrder{RightToLeft: true}, 23) && success
+	success = testOrder([]string{"53", "21", "- "}, worksheet.ReadingOrder{BottomUp: true}, -32) && success
+	success = testOrder([]string{"53", "21", "- "}, worksheet.ReadingOrder{RightToLeft: true, BottomUp: true}, -23) && success
+	success = testOrder([]string{"53", "21", "- "}, worksheet.ColumnMajor, 21) && success
+	success = testOrder([]string{"53", "21", "- "}, worksheet.ReadingOrder{Columns: true, RightToLeft: true}, -21) && success
+	success = testOrder([]string{"53", "21", "- "}, worksheet.ReadingOrder{Columns: true, BottomUp: true}, 12) && success
+	success = testOrder([]string{"53", "21", "- "}, worksheet.ReadingOrder{Columns: true, RightToLeft: true, BottomUp: true}, -12) && success
+	success = testOrder([]string{"123 328  51 64 ", " 45 64  387 23 ", "  6 98  215 314", "*   +   *   +  "}, worksheet.RowMajor, 4277556) && success
+	success = testOrder([]string{"123 328  51 64 ", " 45 64  387 23 ", "  6 98  215 314", "*   +   *   +  "}, worksheet.ReadingOrder{Columns: true, RightToLeft: true}, 3263827) && success
+
+	success = test([]string{"13", "2", "+"}, 15) && success
+	success = test([]string{"123 328  51 64", " 45 64  387 23", "  6 98  215 314", "*   +   *   +"}, 3263827) && success
+	success = test([]string{"*   +   *   +  ", "123 328  51 64 ", " 45 64  387 23 ", "  6 98  215 314"}, 3263827) && success
+	success = test([]string{"1", "2", "+", ""}, 12) && success
+	success = test([]string{"1\t2", "3\t4", "+\t*"}, 37) && success
+	success = test([]string{"1 5", "  6", "3 7", "+ *"}, 580) && success
+
+	success = testWarnings([]string{"123 328  51 64 ", " 45 64  387 23 ", "  6 98  215 314", "*   +   *   +  "}, worksheet.Options{Order: worksheet.ColumnMajor}, []string{}) && success
+	success = testWarnings([]string{"1\t2", "3\t4", "+\t*"}, worksheet.Options{Order: worksheet.ColumnMajor, TabWidth: 4}, []string{
+		"line 1, column 2: tab expanded to width 4, alignment depends on it",
+		"line 2, column 2: tab expanded to width 4, alignment depends on it",
+		"line 3, column 2: tab expanded to width 4, alignment depends on it",
+	}) && success
+	success = testWarnings([]string{"1  2", "3  4", "+ *"}, worksheet.Options{Order: worksheet.ColumnMajor}, []string{
+		"column 3: operator row is the only non-blank line in this column",
+	}) && success
+	success = testWarnings([]string{"1 5", "  6", "3 7", "+ *"}, worksheet.Options{Order: worksheet.ColumnMajor}, []string{
+		`column 1: gap inside number "1 3"`,
+	}) && success
+
+	success = testExplain([]string{"123 328  51 64 ", " 45 64  387 23 ", "  6 98  215 314", "*   +   *   +  "}, []string{
+		"Problem 1, columns 1-3: 1 * 24 * 356 = 8544",
+		"Problem 2, columns 5-7: 369 + 248 + 8 = 625",
+		"Problem 3, columns 9-11: 32 * 581 * 175 = 3253600",
+		"Problem 4, columns 13-15: 623 + 431 + 4 = 1058",
+	}) && success
+
+	success = testExplain(append(slices.Repeat([]string{"91"}, 20), "+ "), []string{
+		"Problem 1, columns 1-2: 99999999999999999999 + 11111111111111111111 = 111111111111111111110",
+	}) && success
+	success = testRender(append(slices.Repeat([]string{"91"}, 20), "+ "), worksheet.RowMajor, []string{
+		"99999999999999999999",
+		"11111111111111111111",
+		"+                   ",
+	}) && success
+	success = testRender([]string{"123 328  51 64 ", " 45 64  387 23 ", "  6 98  215 314", "*   +   *   +  "}, worksheet.ColumnMajor, []string{
+		"123 328 351 644",
+		" 45 64  287 23 ",
+		"  6 98   15 31 ",
+		"*   +   *   +  ",
+	}) && success
+	success = testRender([]string{"123 328  51 64 ", " 45 64  387 23 ", "  6 98  215 314", "*   +   *   +  "}, worksheet.ReadingOrder{Columns: true, RightToLeft: true}, []string{
+		"321 823 153 446",
+		"54   46 782  32",
+		"6    89 51   13",
+		"*   +   *   +  ",
+	}) && success
+	success = testRender([]string{"123 328  51 64 ", " 45 64  387 23 ", "  6 98  215 314", "*   +   *   +  "}, worksheet.RowMajor, []string{
+		"1   369 32  623",
+		"24  248 581 431",
+		"356 8   175 4  ",
+		"*   +   *   +  ",
+	}) && success
+
+	success = testError([]string{"1 2", "3 4", "+ ?"}, `unknown operator "?" at column 3`) && success
+	success = testError([]string{"10 1", "0  2", "/  +"}, `division by zero at column 1`) && success
+	success = testError([]string{"1 2", "3 4", "+  "}, `expected one operator in problem at column 3, got 0`) && success
+	success = testError([]string{"123", "456", "+ +"}, `expected one operator in problem at column 1, got 2`) && success
+
 	if success {
 		fmt.Printf("-=-=-=-=-=-=-=-=-=-=-=-=-\n✅All tests passed!\n")
 	} else {
@@ -128,6 +290,40 @@ func main() {
 
 	input := readInput("input.txt")
 
-	result := run(input)
-	fmt.Printf("Result: %d\n", result)
+	problems, warnings, err := worksheet.ParseTolerant(input, worksheet.Options{Order: worksheet.ColumnMajor, TabWidth: *tabWidth})
+	if err != nil {
+		panic(err)
+	}
+	for _, w := range warnings {
+		fmt.Printf("Warning: %v\n", w)
+	}
+
+	if *explain {
+		lines, err := worksheet.Explain(problems, *alwaysBig)
+		if err != nil {
+			panic(err)
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+	}
+
+	if *csvFilename != "" {
+		file, err := os.Create(*csvFilename)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+
+		err = worksheet.WriteCSV(file, problems, *alwaysBig)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	result, err := worksheet.Evaluate(problems, *alwaysBig)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Result: %v\n", result)
 }
@@ -2,58 +2,279 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
+	"grid"
+	"image"
+	"image/color"
+	"image/png"
 	"os"
 	"strings"
 )
 
-// 0 is emptiness
-// 1 is a beam
-// 2 is a splitter
+// Tiles:
+// S is a source sending a beam down
+// . is emptiness
+// ^ splits a vertical beam into two beams on its left and right, going on in the same direction
+// - splits a vertical beam into a beam going left and a beam going right
+// / and \ are mirrors
+// # absorbs beams
+// Horizontal beams pass ^ and - as if they were empty.
 
-func isSplitter(input []string, x, y int) bool {
-	return input[y][x] == '^'
+type Direction int
+
+const (
+	Up Direction = iota
+	Right
+	Down
+	Left
+)
+
+func (d Direction) delta() (int, int) {
+	switch d {
+	case Up:
+		return 0, -1
+	case Right:
+		return 1, 0
+	case Down:
+		return 0, 1
+	default:
+		return -1, 0
+	}
 }
 
-func isBeamAbove(lines [][]int, x, y int) bool {
-	if y <= 0 {
-		return false
+func (d Direction) isVertical() bool {
+	return d == Up || d == Down
+}
+
+// a beam at (x, y) moving in dir, the tile at (x, y) is applied when the beam enters it
+type Beam struct {
+	x   int
+	y   int
+	dir Direction
+}
+
+func (b Beam) step() Beam {
+	dx, dy := b.dir.delta()
+	return Beam{x: b.x + dx, y: b.y + dy, dir: b.dir}
+}
+
+type TraceResult struct {
+	splits    int // splitters hit at least once
+	energized int // cells with at least one beam
+	absorbed  int // beams ending in an absorber
+	exits     []Beam
+	hasCycle  bool
+
+	beams        *grid.Grid[bool] // cells with at least one beam
+	hitSplitters *grid.Grid[bool]
+}
+
+func parseTile(ch byte) byte {
+	return ch
+}
+
+// next returns the beams leaving b's cell, each of them enters its own cell next
+func next(g *grid.Grid[byte], b Beam) (beams []Beam, split bool, absorbed bool, err error) {
+	switch g.At(b.x, b.y) {
+	case 'S', '.':
+		return []Beam{b.step()}, false, false, nil
+	case '#':
+		return nil, false, true, nil
+	case '/':
+		dirs := map[Direction]Direction{Right: Up, Up: Right, Left: Down, Down: Left}
+		return []Beam{Beam{x: b.x, y: b.y, dir: dirs[b.dir]}.step()}, false, false, nil
+	case '\\':
+		dirs := map[Direction]Direction{Right: Down, Down: Right, Left: Up, Up: Left}
+		return []Beam{Beam{x: b.x, y: b.y, dir: dirs[b.dir]}.step()}, false, false, nil
+	case '-':
+		if !b.dir.isVertical() {
+			return []Beam{b.step()}, false, false, nil
+		}
+		return []Beam{
+			Beam{x: b.x, y: b.y, dir: Left}.step(),
+			Beam{x: b.x, y: b.y, dir: Right}.step(),
+		}, true, false, nil
+	case '^':
+		if !b.dir.isVertical() {
+			return []Beam{b.step()}, false, false, nil
+		}
+		// the new beams appear beside the splitter, their own tiles still apply
+		return []Beam{
+			{x: b.x - 1, y: b.y, dir: b.dir},
+			{x: b.x + 1, y: b.y, dir: b.dir},
+		}, true, false, nil
 	}
-	return lines[y-1][x] == 1
+	return nil, false, false, fmt.Errorf("unknown tile %q at (%d, %d)", g.At(b.x, b.y), b.x, b.y)
 }
 
-func run(input []string) int {
+func trace(g *grid.Grid[byte]) (TraceResult, error) {
+	energized := grid.New(g.Width(), g.Height(), false)
+	splitters := grid.New(g.Width(), g.Height(), false)
+	result := TraceResult{exits: []Beam{}, beams: energized, hitSplitters: splitters}
+
+	energize := func(b Beam) {
+		if g.InBounds(b.x, b.y) && !energized.At(b.x, b.y) {
+			energized.Set(b.x, b.y, true)
+			result.energized++
+		}
+	}
+
+	// depth-first over beam states, a gray state met again closes a cycle
+	const (
+		white = iota
+		gray
+		black
+	)
+	colors := map[Beam]int{}
+
+	type frame struct {
+		beam  Beam
+		beams []Beam
+	}
+	stack := []frame{}
+
+	enter := func(b Beam) error {
+		if !g.InBounds(b.x, b.y) {
+			result.exits = append(result.exits, b)
+			return nil
+		}
+		switch colors[b] {
+		case gray:
+			result.hasCycle = true
+			return nil
+		case black:
+			return nil
+		}
+
+		colors[b] = gray
+		energize(b)
+		beams, split, absorbed, err := next(g, b)
+		if err != nil {
+			return err
+		}
+		if split && !splitters.At(b.x, b.y) {
+			splitters.Set(b.x, b.y, true)
+			result.splits++
+		}
+		if absorbed {
+			result.absorbed++
+		}
+		stack = append(stack, frame{beam: b, beams: beams})
+		return nil
+	}
+
+	for p, tile := range g.All() {
+		if tile != 'S' {
+			continue
+		}
 
-	lines := [][]int{}
-
-	splits := 0
-	for y, line := range input {
-		lineInt := make([]int, len(line))
-
-		if y == 0 {
-			sPos := strings.Index(line, "S")
-			lineInt[sPos] = 1
-		} else {
-			for x := range line {
-				if lineInt[x] == 1 {
-					continue
-				}
-				if isBeamAbove(lines, x, y) {
-					if isSplitter(input, x, y) {
-						lineInt[x-1] = 1
-						lineInt[x+1] = 1
-						lineInt[x] = 2
-						splits++
-					} else {
-						lineInt[x] = 1
-					}
-				}
+		err := enter(Beam{x: p.X, y: p.Y, dir: Down})
+		if err != nil {
+			return result, err
+		}
+		for len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			if len(top.beams) == 0 {
+				colors[top.beam] = black
+				stack = stack[:len(stack)-1]
+				continue
+			}
+
+			b := top.beams[0]
+			top.beams = top.beams[1:]
+			err := enter(b)
+			if err != nil {
+				return result, err
 			}
 		}
-		lines = append(lines, lineInt)
 	}
 
-	return splits
+	return result, nil
+}
+
+func formatExits(exits []Beam, height int) string {
+	parts := []string{}
+	for _, b := range exits {
+		switch {
+		case b.y >= height:
+			parts = append(parts, fmt.Sprintf("bottom %d", b.x))
+		case b.y < 0:
+			parts = append(parts, fmt.Sprintf("top %d", b.x))
+		case b.x < 0:
+			parts = append(parts, fmt.Sprintf("left %d", b.y))
+		default:
+			parts = append(parts, fmt.Sprintf("right %d", b.y))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func getTrace(input []string) (*grid.Grid[byte], TraceResult) {
+	g, err := grid.Parse(input, parseTile)
+	if err != nil {
+		panic(err)
+	}
+
+	result, err := trace(g)
+	if err != nil {
+		panic(err)
+	}
+	return g, result
+}
+
+func run(input []string) int {
+	_, result := getTrace(input)
+	return result.splits
+}
+
+// renderTrace draws beams over empty cells as |, splitters that were hit as * and keeps unused ones as they are
+func renderTrace(g *grid.Grid[byte], result TraceResult) []string {
+	rendered := g.Clone()
+	for p, tile := range g.All() {
+		if result.hitSplitters.At(p.X, p.Y) {
+			rendered.Set(p.X, p.Y, '*')
+		} else if tile == '.' && result.beams.At(p.X, p.Y) {
+			rendered.Set(p.X, p.Y, '|')
+		}
+	}
+	return rendered.Lines(func(tile byte) byte { return tile })
+}
+
+func getTileColor(tile byte, beam, hit bool) color.RGBA {
+	switch {
+	case hit:
+		return color.RGBA{255, 0, 0, 255} // Red
+	case tile == '^' || tile == '-':
+		return color.RGBA{96, 96, 96, 255} // Gray
+	case tile == 'S':
+		return color.RGBA{255, 255, 0, 255} // Yellow
+	case tile == '.' && beam:
+		return color.RGBA{0, 255, 0, 255} // Green
+	case tile == '.':
+		return color.RGBA{0, 0, 0, 255} // Black
+	default:
+		return color.RGBA{0, 0, 255, 255} // Blue
+	}
+}
+
+func saveTraceToPng(g *grid.Grid[byte], result TraceResult, filename string) {
+	img := image.NewRGBA(image.Rect(0, 0, g.Width(), g.Height()))
+
+	for p, tile := range g.All() {
+		img.Set(p.X, p.Y, getTileColor(tile, result.beams.At(p.X, p.Y), result.hitSplitters.At(p.X, p.Y)))
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	err = png.Encode(file, img)
+	if err != nil {
+		panic(err)
+	}
 }
 
 func test(input []string, exp_output int) bool {
@@ -71,6 +292,47 @@ func test(input []string, exp_output int) bool {
 	}
 }
 
+func testTrace(input []string, exp_summary string) bool {
+
+	g, err := grid.Parse(input, parseTile)
+	if err != nil {
+		panic(err)
+	}
+	result, err := trace(g)
+	summary := ""
+	if err != nil {
+		summary = err.Error()
+	} else {
+		summary = fmt.Sprintf("splits=%d energized=%d absorbed=%d cycle=%v exits=[%s]",
+			result.splits, result.energized, result.absorbed, result.hasCycle, formatExits(result.exits, g.Height()))
+	}
+
+	if summary == exp_summary {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual summary: %s\n", summary)
+		fmt.Printf("Expected summary: %s\n", exp_summary)
+		return false
+	}
+}
+
+func testRender(input []string, exp_lines []string) bool {
+
+	lines := renderTrace(getTrace(input))
+
+	if strings.Join(lines, "\n") == strings.Join(exp_lines, "\n") {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual render:\n%s\n", strings.Join(lines, "\n"))
+		fmt.Printf("Expected render:\n%s\n", strings.Join(exp_lines, "\n"))
+		return false
+	}
+}
+
 func readInput(filename string) []string {
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -82,6 +344,10 @@ func readInput(filename string) []string {
 }
 
 func main() {
+	render := flag.Bool("render", false, "print the manifold with its beams and the splitters that were hit")
+	pngFilename := flag.String("png", "", "save the manifold with its beams to this PNG file")
+	flag.Parse()
+
 	success := true
 
 	success = test([]string{
@@ -102,6 +368,66 @@ func main() {
 		".^.^.^.^.^...^.",
 		"...............",
 	}, 21) && success
+	success = test([]string{".S.", "...", ".^.", "...", ""}, 1) && success
+
+	success = testTrace([]string{".S.", "...", "..."}, "splits=0 energized=3 absorbed=0 cycle=false exits=[bottom 1]") && success
+	success = testTrace([]string{".S.", "...", ".^.", "..."}, "splits=1 energized=7 absorbed=0 cycle=false exits=[bottom 0, bottom 2]") && success
+	success = testTrace([]string{"S..", "^..", "..."}, "splits=1 energized=4 absorbed=0 cycle=false exits=[left 1, bottom 1]") && success
+	success = testTrace([]string{"..S", "..^", "..."}, "splits=1 energized=4 absorbed=0 cycle=false exits=[bottom 1, right 1]") && success
+	success = testTrace([]string{".S.", ".#.", "..."}, "splits=0 energized=2 absorbed=1 cycle=false exits=[]") && success
+	success = testTrace([]string{".S.", ".-.", "..."}, "splits=1 energized=4 absorbed=0 cycle=false exits=[left 1, right 1]") && success
+	success = testTrace([]string{".S..", ".\\./", "...."}, "splits=0 energized=5 absorbed=0 cycle=false exits=[top 3]") && success
+	success = testTrace([]string{".S..", "./.\\", "...."}, "splits=0 energized=3 absorbed=0 cycle=false exits=[left 1]") && success
+	success = testTrace([]string{"S...", "\\..\\", "....", "/../"}, "splits=0 energized=10 absorbed=0 cycle=false exits=[bottom 0]") && success
+	success = testTrace([]string{"..S..", ".....", "/.-.\\", ".....", "\\.../"}, "splits=1 energized=14 absorbed=0 cycle=true exits=[]") && success
+	success = testTrace([]string{".S.", ".^#", "..."}, "splits=1 energized=5 absorbed=1 cycle=false exits=[bottom 0]") && success
+	success = testTrace([]string{".S..", ".^/.", "...."}, "splits=1 energized=5 absorbed=0 cycle=false exits=[bottom 0, left 1]") && success
+	success = testTrace([]string{".S..", ".^\\.", "...."}, "splits=1 energized=6 absorbed=0 cycle=false exits=[bottom 0, right 1]") && success
+	success = testTrace([]string{"S..", "^^.", "..."}, "splits=2 energized=5 absorbed=0 cycle=true exits=[left 1, bottom 2]") && success
+	success = testTrace([]string{".S.", ".X.", "..."}, "unknown tile 'X' at (1, 1)") && success
+
+	success = testRender([]string{".S.", "...", ".^.", "...", "^.^"}, []string{
+		".S.",
+		".|.",
+		"|*|",
+		"|.|",
+		"*|*",
+	}) && success
+	success = testRender([]string{
+		".......S.......",
+		"...............",
+		".......^.......",
+		"...............",
+		"......^.^......",
+		"...............",
+		".....^.^.^.....",
+		"...............",
+		"....^.^...^....",
+		"...............",
+		"...^.^...^.^...",
+		"...............",
+		"..^...^.....^..",
+		"...............",
+		".^.^.^.^.^...^.",
+		"...............",
+	}, []string{
+		".......S.......",
+		".......|.......",
+		"......|*|......",
+		"......|.|......",
+		".....|*|*|.....",
+		".....|.|.|.....",
+		"....|*|*|*|....",
+		"....|.|.|.|....",
+		"...|*|*|||*|...",
+		"...|.|.|||.|...",
+		"..|*|*|||*|*|..",
+		"..|.|.|||.|.|..",
+		".|*|||*||.||*|.",
+		".|.|||.||.||.|.",
+		"|*|*|*|*|^|||*|",
+		"|.|.|.|.|.|||.|",
+	}) && success
 
 	if success {
 		fmt.Printf("-=-=-=-=-=-=-=-=-=-=-=-=-\n✅All tests passed!\n")
@@ -114,4 +440,14 @@ func main() {
 
 	result := run(input)
 	fmt.Printf("Result: %d\n", result)
+
+	if *render || *pngFilename != "" {
+		g, result := getTrace(input)
+		if *render {
+			fmt.Println(strings.Join(renderTrace(g, result), "\n"))
+		}
+		if *pngFilename != "" {
+			saveTraceToPng(g, result, *pngFilename)
+		}
+	}
 }
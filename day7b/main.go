@@ -2,92 +2,570 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
+	"grid"
+	"image"
+	"image/color"
+	"image/png"
+	"math/big"
+	"math/bits"
 	"os"
 	"strings"
 )
 
-// 0 is emptiness
-// 1 is a beam
-// 2 is a splitter
+// Tiles:
+// S is a source sending a beam down
+// . is emptiness
+// ^ splits a beam into two timelines going on down from its left and right
 
-func getStartCoords(input []string) (int, int) {
-	for y, line := range input {
-		sPos := strings.Index(line, "S")
-		if sPos != -1 {
-			return sPos, y
+type Timelines struct {
+	columns []*big.Int // timelines leaving through the bottom of each column
+	left    *big.Int   // timelines leaving through the left edge
+	right   *big.Int   // timelines leaving through the right edge
+
+	cells *grid.Grid[*big.Int] // timelines with a beam in each cell
+}
+
+func (t Timelines) total() *big.Int {
+	total := new(big.Int).Add(t.left, t.right)
+	for _, count := range t.columns {
+		total.Add(total, count)
+	}
+	return total
+}
+
+func parseTile(ch byte) byte {
+	return ch
+}
+
+func getSources(g *grid.Grid[byte]) []grid.Point {
+	sources := []grid.Point{}
+	for p, tile := range g.All() {
+		if tile == 'S' {
+			sources = append(sources, p)
 		}
 	}
-	return -1, -1
+	return sources
 }
 
-func isEmpty(input []string, x, y int) bool {
-	if y < 0 || y >= len(input) || x < 0 || x >= len(input[0]) {
-		return true
+func isEmpty(g *grid.Grid[byte], x, y int) bool {
+	tile, ok := g.Get(x, y)
+	return !ok || tile == '.' || tile == 'S'
+}
+func isSplitter(g *grid.Grid[byte], x, y int) bool {
+	return g.At(x, y) == '^'
+}
+
+func addUint64(a, b uint64) (uint64, bool) {
+	sum, carry := bits.Add64(a, b, 0)
+	return sum, carry == 0
+}
+
+func addBig(a, b *big.Int) (*big.Int, bool) {
+	return new(big.Int).Add(a, b), true
+}
+
+// Flow is what leaves the manifold and what passes each cell, as timeline counts or as probabilities
+type Flow[T any] struct {
+	columns []T
+	left    T
+	right   T
+	cells   *grid.Grid[T]
+}
+
+// propagateWith carries an amount in each column from one row to the next, split decides how much of it
+// goes to each side of a splitter, it returns false as soon as add reports an overflow
+func propagateWith[T any](g *grid.Grid[byte], sources []grid.Point, zero, one T, add func(a, b T) (T, bool), split func(p grid.Point, amount T) (T, T)) (Flow[T], bool, error) {
+	width := g.Width()
+	counts := make([]T, width)
+	lit := make([]bool, width)
+	left, right := zero, zero
+	cells := grid.New(width, g.Height(), zero)
+	ok := true
+
+	addTo := func(total *T, count T) {
+		var fits bool
+		*total, fits = add(*total, count)
+		ok = ok && fits
 	}
-	return input[y][x] == '.'
+
+	for y := range g.Height() {
+		for _, p := range sources {
+			if p.Y == y {
+				if !lit[p.X] {
+					counts[p.X], lit[p.X] = zero, true
+				}
+				addTo(&counts[p.X], one)
+			}
+		}
+		for x := range width {
+			if lit[x] {
+				cells.Set(x, y, counts[x])
+			}
+		}
+
+		if y+1 == g.Height() {
+			break
+		}
+
+		next := make([]T, width)
+		nextLit := make([]bool, width)
+		moveTo := func(x int, count T) {
+			switch {
+			case x < 0:
+				addTo(&left, count)
+			case x >= width:
+				addTo(&right, count)
+			default:
+				if !nextLit[x] {
+					next[x], nextLit[x] = zero, true
+				}
+				addTo(&next[x], count)
+			}
+		}
+
+		for x := range width {
+			if !lit[x] {
+				continue
+			}
+			if isSplitter(g, x, y+1) {
+				toLeft, toRight := split(grid.Point{X: x, Y: y + 1}, counts[x])
+				moveTo(x-1, toLeft)
+				moveTo(x+1, toRight)
+			} else if isEmpty(g, x, y+1) {
+				moveTo(x, counts[x])
+			} else {
+				return Flow[T]{}, false, fmt.Errorf("beam blocked by %q at (%d, %d)", g.At(x, y+1), x, y+1)
+			}
+		}
+		if !ok {
+			return Flow[T]{}, false, nil
+		}
+		counts, lit = next, nextLit
+	}
+
+	flow := Flow[T]{columns: make([]T, width), left: left, right: right, cells: cells}
+	for x := range width {
+		flow.columns[x] = zero
+		if lit[x] {
+			flow.columns[x] = counts[x]
+		}
+	}
+	return flow, ok, nil
 }
-func isSplitter(input []string, x, y int) bool {
-	if y < 0 || y >= len(input) || x < 0 || x >= len(input[0]) {
-		return false
+
+// every timeline reaching a splitter goes on both ways
+func splitTimelines[T any](_ grid.Point, count T) (T, T) {
+	return count, count
+}
+
+func toTimelines[T any](flow Flow[T], toBig func(T) *big.Int) Timelines {
+	timelines := Timelines{
+		columns: make([]*big.Int, len(flow.columns)),
+		left:    toBig(flow.left),
+		right:   toBig(flow.right),
+		cells:   grid.New(flow.cells.Width(), flow.cells.Height(), big.NewInt(0)),
+	}
+	for x, count := range flow.columns {
+		timelines.columns[x] = toBig(count)
+	}
+	for p, count := range flow.cells.All() {
+		timelines.cells.Set(p.X, p.Y, toBig(count))
+	}
+	return timelines
+}
+
+// propagate counts in uint64 and redoes the whole propagation in big when a count overflows
+func propagate(g *grid.Grid[byte], sources []grid.Point) (Timelines, error) {
+	flow, ok, err := propagateWith(g, sources, uint64(0), uint64(1), addUint64, splitTimelines)
+	if err != nil {
+		return Timelines{}, err
+	}
+	if ok {
+		return toTimelines(flow, func(n uint64) *big.Int { return new(big.Int).SetUint64(n) }), nil
+	}
+
+	bigFlow, _, err := propagateWith(g, sources, big.NewInt(0), big.NewInt(1), addBig, splitTimelines)
+	if err != nil {
+		return Timelines{}, err
+	}
+	return toTimelines(bigFlow, func(n *big.Int) *big.Int { return n }), nil
+}
+
+// probability for each splitter to send the beam left, the others send it left half of the time
+type Weights map[grid.Point]*big.Rat
+
+var half = big.NewRat(1, 2)
+
+// parseWeights reads lines like "7,2 1/3" or "7,2 0.25" giving the probability of going left for the splitter at (7, 2)
+func parseWeights(lines []string) (Weights, error) {
+	weights := Weights{}
+	for ind, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		var x, y int
+		var value string
+		_, err := fmt.Sscanf(line, "%d,%d %s", &x, &y, &value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: expected \"x,y p\", got %q", ind+1, line)
+		}
+		p, ok := new(big.Rat).SetString(value)
+		if !ok || p.Sign() < 0 || p.Cmp(big.NewRat(1, 1)) > 0 {
+			return nil, fmt.Errorf("line %d: probability must be between 0 and 1, got %q", ind+1, value)
+		}
+		weights[grid.Point{X: x, Y: y}] = p
+	}
+	return weights, nil
+}
+
+func addRat(a, b *big.Rat) (*big.Rat, bool) {
+	return new(big.Rat).Add(a, b), true
+}
+
+// propagateWeighted gives the probability of the beam from source leaving through each exit
+// and the expected number of splitters it activates
+func propagateWeighted(g *grid.Grid[byte], source grid.Point, weights Weights) (Flow[*big.Rat], *big.Rat, error) {
+	for p := range weights {
+		tile, ok := g.Get(p.X, p.Y)
+		if !ok || tile != '^' {
+			return Flow[*big.Rat]{}, nil, fmt.Errorf("no splitter at (%d, %d) to weight", p.X, p.Y)
+		}
+	}
+
+	activations := new(big.Rat)
+	split := func(p grid.Point, probability *big.Rat) (*big.Rat, *big.Rat) {
+		activations.Add(activations, probability)
+		weight, ok := weights[p]
+		if !ok {
+			weight = half
+		}
+		toLeft := new(big.Rat).Mul(probability, weight)
+		toRight := new(big.Rat).Sub(probability, toLeft)
+		return toLeft, toRight
+	}
+
+	flow, _, err := propagateWith(g, []grid.Point{source}, new(big.Rat), big.NewRat(1, 1), addRat, split)
+	if err != nil {
+		return Flow[*big.Rat]{}, nil, err
+	}
+	return flow, activations, nil
+}
+
+// formatProbabilities has a line per exit the beam can leave through, then the expected activations
+func formatProbabilities(flow Flow[*big.Rat], activations *big.Rat) []string {
+	lines := []string{}
+	if flow.left.Sign() != 0 {
+		lines = append(lines, fmt.Sprintf("left: %s", flow.left.RatString()))
+	}
+	for x, probability := range flow.columns {
+		if probability.Sign() != 0 {
+			lines = append(lines, fmt.Sprintf("%d: %s", x, probability.RatString()))
+		}
+	}
+	if flow.right.Sign() != 0 {
+		lines = append(lines, fmt.Sprintf("right: %s", flow.right.RatString()))
+	}
+	return append(lines, fmt.Sprintf("activations: %s", activations.RatString()))
+}
+
+func getProbabilities(input []string, weightLines []string) ([]string, error) {
+	g, err := grid.Parse(input, parseTile)
+	if err != nil {
+		return nil, err
+	}
+	weights, err := parseWeights(weightLines)
+	if err != nil {
+		return nil, err
+	}
+
+	// every source is its own beam, so the probabilities of each one add up to 1
+	sources := getSources(g)
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no source in the manifold")
+	}
+	lines := []string{}
+	for _, source := range sources {
+		flow, activations, err := propagateWeighted(g, source, weights)
+		if err != nil {
+			return nil, err
+		}
+		if len(sources) > 1 {
+			lines = append(lines, fmt.Sprintf("Source (%d, %d):", source.X, source.Y))
+		}
+		lines = append(lines, formatProbabilities(flow, activations)...)
+	}
+	return lines, nil
+}
+
+func propagatePerSource(g *grid.Grid[byte], sources []grid.Point) ([]Timelines, error) {
+	perSource := []Timelines{}
+	for _, source := range sources {
+		timelines, err := propagate(g, []grid.Point{source})
+		if err != nil {
+			return nil, err
+		}
+		perSource = append(perSource, timelines)
+	}
+	return perSource, nil
+}
+
+func getTimelines(input []string) Timelines {
+	g, err := grid.Parse(input, parseTile)
+	if err != nil {
+		panic(err)
+	}
+
+	timelines, err := propagate(g, getSources(g))
+	if err != nil {
+		panic(err)
+	}
+	return timelines
+}
+
+func run(input []string) *big.Int {
+	return getTimelines(input).total()
+}
+
+const histogramWidth = 40
+
+// formatHistogram has a line per exit with at least one timeline, bars are scaled to the biggest exit
+func formatHistogram(timelines Timelines) []string {
+	labels := []string{"left"}
+	counts := []*big.Int{timelines.left}
+	for x, count := range timelines.columns {
+		labels = append(labels, fmt.Sprintf("%d", x))
+		counts = append(counts, count)
+	}
+	labels = append(labels, "right")
+	counts = append(counts, timelines.right)
+
+	maxCount := big.NewInt(0)
+	for _, count := range counts {
+		if count.Cmp(maxCount) > 0 {
+			maxCount = count
+		}
+	}
+
+	lines := []string{}
+	for ind, count := range counts {
+		if count.Sign() == 0 {
+			continue
+		}
+		bar := new(big.Int).Mul(count, big.NewInt(histogramWidth))
+		bar.Quo(bar, maxCount)
+		lines = append(lines, fmt.Sprintf("%5s | %s %s", labels[ind], strings.Repeat("#", max(int(bar.Int64()), 1)), count))
 	}
-	return input[y][x] == '^'
+	return lines
 }
 
-var cacheSplits [][]int
+func getMaxBits(timelines Timelines) int {
+	maxBits := 0
+	for _, count := range timelines.cells.All() {
+		maxBits = max(maxBits, count.BitLen())
+	}
+	return maxBits
+}
 
-func makeTurnFrom(input []string, curX, curY int) int {
-	if curY >= len(input) {
+// getHeatLevel puts a count on a log2 scale from 1 to levels, 0 stays 0
+func getHeatLevel(count *big.Int, maxBits, levels int) int {
+	if count.Sign() == 0 {
 		return 0
 	}
+	if maxBits <= 1 {
+		return 1
+	}
+	return 1 + (levels-1)*(count.BitLen()-1)/(maxBits-1)
+}
 
-	if cacheSplits[curY][curX] != 0 {
-		return cacheSplits[curY][curX]
+// formatHeatmap draws the timelines in each empty cell as a digit from 1 to 9 on a log2 scale
+func formatHeatmap(g *grid.Grid[byte], timelines Timelines) []string {
+	maxBits := getMaxBits(timelines)
+	heatmap := g.Clone()
+	for p, count := range timelines.cells.All() {
+		level := getHeatLevel(count, maxBits, 9)
+		if level > 0 && g.At(p.X, p.Y) == '.' {
+			heatmap.Set(p.X, p.Y, byte('0'+level))
+		}
 	}
+	return heatmap.Lines(func(tile byte) byte { return tile })
+}
 
-	if isEmpty(input, curX, curY+1) {
-		splits := makeTurnFrom(input, curX, curY+1)
-		cacheSplits[curY][curX] = splits
-		return splits
-	} else if isSplitter(input, curX, curY+1) {
-		splitsA := makeTurnFrom(input, curX-1, curY+1)
-		splitsB := makeTurnFrom(input, curX+1, curY+1)
-		splits := splitsA + splitsB + 1
-		cacheSplits[curY][curX] = splits
-		return splits
-	} else {
-		panic("Beam blocked")
+func getHeatColor(tile byte, level, levels int) color.RGBA {
+	if tile == '^' {
+		return color.RGBA{96, 96, 96, 255} // Gray
+	}
+	if level == 0 {
+		return color.RGBA{0, 0, 0, 255} // Black
 	}
+	// Dark red for a single timeline up to yellow for the most crowded cells
+	heat := 255 * (level - 1) / max(levels-1, 1)
+	return color.RGBA{uint8(128 + heat/2), uint8(heat), 0, 255}
 }
 
-func run(input []string) int {
+func saveHeatmapToPng(g *grid.Grid[byte], timelines Timelines, filename string) {
+	const levels = 256
+	maxBits := getMaxBits(timelines)
+	img := image.NewRGBA(image.Rect(0, 0, g.Width(), g.Height()))
 
-	cacheSplits = make([][]int, len(input))
-	for i := range cacheSplits {
-		cacheSplits[i] = make([]int, len(input[0]))
+	for p, count := range timelines.cells.All() {
+		img.Set(p.X, p.Y, getHeatColor(g.At(p.X, p.Y), getHeatLevel(count, maxBits, levels), levels))
 	}
 
-	curX, curY := getStartCoords(input)
-	splits := makeTurnFrom(input, curX, curY)
+	file, err := os.Create(filename)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
 
-	return splits + 1
+	err = png.Encode(file, img)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func saveDistribution(timelines Timelines, filename string) {
+	var sb strings.Builder
+	sb.WriteString("exit,timelines\n")
+	sb.WriteString(fmt.Sprintf("left,%s\n", timelines.left))
+	for x, count := range timelines.columns {
+		sb.WriteString(fmt.Sprintf("%d,%s\n", x, count))
+	}
+	sb.WriteString(fmt.Sprintf("right,%s\n", timelines.right))
+
+	err := os.WriteFile(filename, []byte(sb.String()), 0644)
+	if err != nil {
+		panic(err)
+	}
 }
 
 func test(input []string, exp_output int) bool {
 
 	output := run(input)
 
-	if output == exp_output {
+	if output.Cmp(big.NewInt(int64(exp_output))) == 0 {
 		fmt.Printf("✅Test passed: %v\n", input)
 		return true
 	} else {
 		fmt.Printf("❌Test failed: %v\n", input)
-		fmt.Printf("Actual output: %d\n", output)
+		fmt.Printf("Actual output: %s\n", output)
 		fmt.Printf("Expected output: %d\n", exp_output)
 		return false
 	}
 }
 
+func testBig(rows int, width int, exp_output string) bool {
+
+	input := []string{strings.Repeat(".", width/2) + "S" + strings.Repeat(".", width/2)}
+	for range rows {
+		input = append(input, strings.Repeat("^", width))
+	}
+	output := run(input)
+
+	if output.String() == exp_output {
+		fmt.Printf("✅Test passed: %d rows of splitters\n", rows)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %d rows of splitters\n", rows)
+		fmt.Printf("Actual output: %s\n", output)
+		fmt.Printf("Expected output: %s\n", exp_output)
+		return false
+	}
+}
+
+func testHistogram(input []string, exp_lines []string) bool {
+
+	lines := formatHistogram(getTimelines(input))
+
+	if strings.Join(lines, "\n") == strings.Join(exp_lines, "\n") {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual histogram:\n%s\n", strings.Join(lines, "\n"))
+		fmt.Printf("Expected histogram:\n%s\n", strings.Join(exp_lines, "\n"))
+		return false
+	}
+}
+
+func testHeatmap(input []string, exp_lines []string) bool {
+
+	g, err := grid.Parse(input, parseTile)
+	if err != nil {
+		panic(err)
+	}
+	lines := formatHeatmap(g, getTimelines(input))
+
+	if strings.Join(lines, "\n") == strings.Join(exp_lines, "\n") {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual heatmap:\n%s\n", strings.Join(lines, "\n"))
+		fmt.Printf("Expected heatmap:\n%s\n", strings.Join(exp_lines, "\n"))
+		return false
+	}
+}
+
+func testWeighted(input []string, weightLines []string, exp_lines []string) bool {
+
+	lines, err := getProbabilities(input, weightLines)
+	if err != nil {
+		lines = []string{err.Error()}
+	}
+
+	if strings.Join(lines, "\n") == strings.Join(exp_lines, "\n") {
+		fmt.Printf("✅Test passed: %v %v\n", input, weightLines)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v %v\n", input, weightLines)
+		fmt.Printf("Actual probabilities:\n%s\n", strings.Join(lines, "\n"))
+		fmt.Printf("Expected probabilities:\n%s\n", strings.Join(exp_lines, "\n"))
+		return false
+	}
+}
+
+func testPerSource(input []string, exp_totals []string) bool {
+
+	g, err := grid.Parse(input, parseTile)
+	if err != nil {
+		panic(err)
+	}
+	perSource, err := propagatePerSource(g, getSources(g))
+	if err != nil {
+		panic(err)
+	}
+	combined := getTimelines(input)
+
+	totals := []string{}
+	sum := Timelines{columns: make([]*big.Int, g.Width()), left: big.NewInt(0), right: big.NewInt(0)}
+	for x := range sum.columns {
+		sum.columns[x] = big.NewInt(0)
+	}
+	for _, timelines := range perSource {
+		totals = append(totals, timelines.total().String())
+		sum.left.Add(sum.left, timelines.left)
+		sum.right.Add(sum.right, timelines.right)
+		for x, count := range timelines.columns {
+			sum.columns[x].Add(sum.columns[x], count)
+		}
+	}
+
+	if strings.Join(totals, " ") == strings.Join(exp_totals, " ") &&
+		strings.Join(formatHistogram(sum), "\n") == strings.Join(formatHistogram(combined), "\n") {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual totals: %v, combined: %s\n", totals, combined.total())
+		fmt.Printf("Expected totals: %v\n", exp_totals)
+		return false
+	}
+}
+
 func readInput(filename string) []string {
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -99,6 +577,14 @@ func readInput(filename string) []string {
 }
 
 func main() {
+	histogram := flag.Bool("histogram", false, "print how many timelines leave through each exit")
+	csvFilename := flag.String("csv", "", "export the timelines per exit to this CSV file")
+	heatmap := flag.Bool("heatmap", false, "print how many timelines pass each cell on a log2 scale")
+	pngFilename := flag.String("png", "", "save the timelines heatmap to this PNG file")
+	weightsFilename := flag.String("weights", "", "print the exit probabilities with the splitter weights from this file")
+	perSource := flag.Bool("per-source", false, "evaluate every source on its own instead of combined")
+	flag.Parse()
+
 	success := true
 
 	success = test([]string{
@@ -137,6 +623,201 @@ func main() {
 		".^.^.^.^.^...^.",
 		"...............",
 	}, 40) && success
+	success = test([]string{".S.", "...", ".^.", "...", ""}, 2) && success
+	success = test([]string{
+		"S.S",
+		"...",
+		".^.",
+		"...",
+	}, 2) && success
+	success = test([]string{
+		".S.",
+		".S.",
+		".^.",
+		"...",
+	}, 4) && success
+	success = testBig(10, 21, "1024") && success
+	success = testBig(63, 127, "9223372036854775808") && success
+	success = testBig(64, 129, "18446744073709551616") && success
+	success = testBig(70, 141, "1180591620717411303424") && success
+
+	success = testHistogram([]string{
+		".S.",
+		"...",
+		".^.",
+		"...",
+	}, []string{
+		"    0 | ######################################## 1",
+		"    2 | ######################################## 1",
+	}) && success
+	success = testHistogram([]string{
+		"S....",
+		"^....",
+		"..S..",
+		"..^..",
+		".^.^.",
+	}, []string{
+		" left | ############# 1",
+		"    0 | ########################## 2",
+		"    2 | ######################################## 3",
+		"    4 | ############# 1",
+	}) && success
+	success = testHistogram([]string{
+		".......S.......",
+		"...............",
+		".......^.......",
+		"...............",
+		"......^.^......",
+		"...............",
+		".....^.^.^.....",
+		"...............",
+		"....^.^...^....",
+		"...............",
+		"...^.^...^.^...",
+		"...............",
+		"..^...^.....^..",
+		"...............",
+		".^.^.^.^.^...^.",
+		"...............",
+	}, []string{
+		"    0 | ### 1",
+		"    2 | ####### 2",
+		"    4 | #################################### 10",
+		"    6 | ######################################## 11",
+		"    8 | ######################################## 11",
+		"   10 | ####### 2",
+		"   11 | ### 1",
+		"   12 | ### 1",
+		"   14 | ### 1",
+	}) && success
+	success = testHeatmap([]string{
+		".S.",
+		"...",
+		".^.",
+		"...",
+	}, []string{
+		".S.",
+		".1.",
+		"1^1",
+		"1.1",
+	}) && success
+	success = testHeatmap([]string{
+		".......S.......",
+		"...............",
+		".......^.......",
+		"...............",
+		"......^.^......",
+		"...............",
+		".....^.^.^.....",
+		"...............",
+		"....^.^...^....",
+		"...............",
+		"...^.^...^.^...",
+		"...............",
+		"..^...^.....^..",
+		"...............",
+		".^.^.^.^.^...^.",
+		"...............",
+	}, []string{
+		".......S.......",
+		".......1.......",
+		"......1^1......",
+		"......1.1......",
+		".....1^3^1.....",
+		".....1.3.1.....",
+		"....1^3^3^1....",
+		"....1.3.3.1....",
+		"...1^6^331^1...",
+		"...1.6.331.1...",
+		"..1^6^636^3^1..",
+		"..1.6.636.3.1..",
+		".1^166^66.31^1.",
+		".1.166.66.31.1.",
+		"1^3^9^9^9^311^1",
+		"1.3.9.9.9.311.1",
+	}) && success
+	success = testPerSource([]string{
+		"S..S.",
+		"^....",
+		"...^.",
+		"..^..",
+	}, []string{"2", "3"}) && success
+
+	success = testWeighted([]string{".S.", "...", ".^.", "..."}, []string{}, []string{
+		"0: 1/2",
+		"2: 1/2",
+		"activations: 1",
+	}) && success
+	success = testWeighted([]string{".S.", "...", ".^.", "..."}, []string{"1,2 1/3"}, []string{
+		"0: 1/3",
+		"2: 2/3",
+		"activations: 1",
+	}) && success
+	success = testWeighted([]string{".S.", "...", ".^.", "...", "^.^", "..."}, []string{"1,2 0.25", "", "0,4 1/2"}, []string{
+		"left: 1/8",
+		"1: 1/2",
+		"right: 3/8",
+		"activations: 2",
+	}) && success
+	success = testWeighted([]string{".S.", "...", ".^.", "...", "^.^", "..."}, []string{"1,2 1", "2,4 0"}, []string{
+		"left: 1/2",
+		"1: 1/2",
+		"activations: 2",
+	}) && success
+	success = testWeighted([]string{
+		".......S.......",
+		"...............",
+		".......^.......",
+		"...............",
+		"......^.^......",
+		"...............",
+		".....^.^.^.....",
+		"...............",
+		"....^.^...^....",
+		"...............",
+		"...^.^...^.^...",
+		"...............",
+		"..^...^.....^..",
+		"...............",
+		".^.^.^.^.^...^.",
+		"...............",
+	}, []string{}, []string{
+		"0: 1/128",
+		"2: 1/64",
+		"4: 25/128",
+		"6: 5/32",
+		"8: 17/32",
+		"10: 1/16",
+		"11: 1/64",
+		"12: 1/128",
+		"14: 1/128",
+		"activations: 295/64",
+	}) && success
+	success = testWeighted([]string{"S..S.", "^....", "...^.", "..^.."}, []string{"0,1 1/3", "3,2 1/4"}, []string{
+		"Source (0, 0):",
+		"left: 1/3",
+		"1: 2/3",
+		"activations: 1",
+		"Source (3, 0):",
+		"1: 1/8",
+		"3: 1/8",
+		"4: 3/4",
+		"activations: 5/4",
+	}) && success
+	success = testWeighted([]string{".S.", ".S.", ".^.", "..."}, []string{}, []string{
+		"Source (1, 0):",
+		"0: 1/2",
+		"2: 1/2",
+		"activations: 1",
+		"Source (1, 1):",
+		"0: 1/2",
+		"2: 1/2",
+		"activations: 1",
+	}) && success
+	success = testWeighted([]string{"...", ".^.", "..."}, []string{}, []string{"no source in the manifold"}) && success
+	success = testWeighted([]string{".S.", "...", ".^.", "..."}, []string{"1,1 1/3"}, []string{"no splitter at (1, 1) to weight"}) && success
+	success = testWeighted([]string{".S.", "...", ".^.", "..."}, []string{"1,2 4/3"}, []string{`line 1: probability must be between 0 and 1, got "4/3"`}) && success
+	success = testWeighted([]string{".S.", "...", ".^.", "..."}, []string{"1 2 1/3"}, []string{`line 1: expected "x,y p", got "1 2 1/3"`}) && success
 
 	if success {
 		fmt.Printf("-=-=-=-=-=-=-=-=-=-=-=-=-\n✅All tests passed!\n")
@@ -148,5 +829,49 @@ func main() {
 	input := readInput("input.txt")
 
 	result := run(input)
-	fmt.Printf("Result: %d\n", result)
+	fmt.Printf("Result: %s\n", result)
+
+	timelines := getTimelines(input)
+	if *histogram {
+		fmt.Println(strings.Join(formatHistogram(timelines), "\n"))
+	}
+	if *csvFilename != "" {
+		saveDistribution(timelines, *csvFilename)
+	}
+	if *heatmap || *pngFilename != "" {
+		g, err := grid.Parse(input, parseTile)
+		if err != nil {
+			panic(err)
+		}
+		if *heatmap {
+			fmt.Println(strings.Join(formatHeatmap(g, timelines), "\n"))
+		}
+		if *pngFilename != "" {
+			saveHeatmapToPng(g, timelines, *pngFilename)
+		}
+	}
+	if *weightsFilename != "" {
+		lines, err := getProbabilities(input, readInput(*weightsFilename))
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(strings.Join(lines, "\n"))
+	}
+	if *perSource {
+		g, err := grid.Parse(input, parseTile)
+		if err != nil {
+			panic(err)
+		}
+		sources := getSources(g)
+		perSourceTimelines, err := propagatePerSource(g, sources)
+		if err != nil {
+			panic(err)
+		}
+		for ind, timelines := range perSourceTimelines {
+			fmt.Printf("Source (%d, %d): %s\n", sources[ind].X, sources[ind].Y, timelines.total())
+			if *histogram {
+				fmt.Println(strings.Join(formatHistogram(timelines), "\n"))
+			}
+		}
+	}
 }
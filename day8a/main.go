@@ -5,8 +5,8 @@ import (
 	"fmt"
 	"math"
 	"os"
-	"sort"
 	"strings"
+	"unionfind"
 )
 
 type Point3D struct {
@@ -75,85 +75,32 @@ func getMinDist(dists [][]float64) (int, int, [][]float64) {
 	return minI, minJ, dists
 }
 
-func isInCurcuit(curcuit []int, point int) bool {
-	for _, p := range curcuit {
-		if p == point {
-			return true
-		}
-	}
-	return false
-}
-
-func areConnected(curcuits [][]int, i int, j int) bool {
-	for _, curcuit := range curcuits {
-		if isInCurcuit(curcuit, i) && isInCurcuit(curcuit, j) {
-			return true
-		}
-	}
-	return false
-}
-
-func connectPoints(curcuits [][]int, i int, j int) [][]int {
-	curcuitI := -1
-	curcuitJ := -1
-
-	for idx, curcuit := range curcuits {
-		if isInCurcuit(curcuit, i) {
-			curcuitI = idx
-		}
-		if isInCurcuit(curcuit, j) {
-			curcuitJ = idx
-		}
-	}
-
-	if curcuitI == -1 && curcuitJ == -1 {
-		curcuits = append(curcuits, []int{i, j})
-	} else if curcuitI != -1 && curcuitJ == -1 {
-		curcuits[curcuitI] = append(curcuits[curcuitI], j)
-	} else if curcuitI == -1 && curcuitJ != -1 {
-		curcuits[curcuitJ] = append(curcuits[curcuitJ], i)
-	} else if curcuitI != curcuitJ {
-		curcuits[curcuitI] = append(curcuits[curcuitI], curcuits[curcuitJ]...)
-		curcuits = append(curcuits[:curcuitJ], curcuits[curcuitJ+1:]...)
-	}
-
-	return curcuits
-}
-
-func multiplyBiggestCircuits(curcuits [][]int, numToMul int) int {
-	curcuitsLengths := []int{}
-	for _, curcuit := range curcuits {
-		curcuitsLengths = append(curcuitsLengths, len(curcuit))
-	}
-
-	sort.Ints(curcuitsLengths)
+func multiplyBiggestCircuits(circuits *unionfind.UnionFind, numToMul int) int {
 	total := 1
-	for i := 0; i < numToMul; i++ {
-		total *= curcuitsLengths[len(curcuitsLengths)-1-i]
+	for _, size := range circuits.Largest(numToMul) {
+		total *= size
 	}
 	return total
 }
 
-func run(input []string, connections int, numToMul int) int {
+func connect(input []string, connections int) *unionfind.UnionFind {
 	points := getPoints(input)
 	dists := getDistances(points)
-	curcuits := [][]int{}
+	circuits := unionfind.New(len(points))
 
 	conn := 0
 	for conn < connections {
 		minI, minJ := -1, -1
 		minI, minJ, dists = getMinDist(dists)
-		if areConnected(curcuits, minI, minJ) {
-			conn++
-			continue
-		}
-		curcuits = connectPoints(curcuits, minI, minJ)
+		circuits.Union(minI, minJ)
 		conn++
 	}
 
-	res := multiplyBiggestCircuits(curcuits, numToMul)
+	return circuits
+}
 
-	return res
+func run(input []string, connections int, numToMul int) int {
+	return multiplyBiggestCircuits(connect(input, connections), numToMul)
 }
 
 func test(input []string, connections int, numToMul int, exp_output int) bool {
@@ -171,6 +118,22 @@ func test(input []string, connections int, numToMul int, exp_output int) bool {
 	}
 }
 
+func testCircuits(input []string, connections int, exp_sizes []int) bool {
+
+	circuits := connect(input, connections)
+	sizes := circuits.Largest(circuits.Len())
+
+	if fmt.Sprint(sizes) == fmt.Sprint(exp_sizes) && circuits.Count() == len(exp_sizes) {
+		fmt.Printf("✅Test passed: %v\n", input)
+		return true
+	} else {
+		fmt.Printf("❌Test failed: %v\n", input)
+		fmt.Printf("Actual sizes: %v (%d circuits)\n", sizes, circuits.Count())
+		fmt.Printf("Expected sizes: %v\n", exp_sizes)
+		return false
+	}
+}
+
 func readInput(filename string) []string {
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -206,6 +169,29 @@ func main() {
 		"984,92,344",
 		"425,690,689",
 	}, 10, 3, 40) && success
+	success = testCircuits([]string{
+		"162,817,812",
+		"57,618,57",
+		"906,360,560",
+		"592,479,940",
+		"352,342,300",
+		"466,668,158",
+		"542,29,236",
+		"431,825,988",
+		"739,650,466",
+		"52,470,668",
+		"216,146,977",
+		"819,987,18",
+		"117,168,530",
+		"805,96,715",
+		"346,949,466",
+		"970,615,88",
+		"941,993,340",
+		"862,61,35",
+		"984,92,344",
+		"425,690,689",
+	}, 10, []int{5, 4, 2, 2, 1, 1, 1, 1, 1, 1, 1}) && success
+	success = test([]string{"0,0,0", "1,0,0", "10,0,0", "12,0,0"}, 1, 3, 2) && success
 
 	if success {
 		fmt.Printf("-=-=-=-=-=-=-=-=-=-=-=-=-\n✅All tests passed!\n")
@@ -1,3 +1,7 @@
 module day8b
 
 go 1.25.4
+
+require unionfind v0.0.0
+
+replace unionfind => ../unionfind
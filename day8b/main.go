@@ -6,6 +6,7 @@ import (
 	"math"
 	"os"
 	"strings"
+	"unionfind"
 )
 
 type Point3D struct {
@@ -74,68 +75,22 @@ func getMinDist(dists [][]float64) (int, int, [][]float64) {
 	return minI, minJ, dists
 }
 
-func isInCurcuit(curcuit []int, point int) bool {
-	for _, p := range curcuit {
-		if p == point {
-			return true
-		}
-	}
-	return false
-}
-
-func connectPoints(curcuits [][]int, i int, j int) [][]int {
-	curcuitI := -1
-	curcuitJ := -1
-
-	for idx, curcuit := range curcuits {
-		if isInCurcuit(curcuit, i) {
-			curcuitI = idx
-		}
-		if isInCurcuit(curcuit, j) {
-			curcuitJ = idx
-		}
-	}
-
-	if curcuitI == curcuitJ && curcuitI != -1 {
-		return curcuits
-	}
-
-	if curcuitI == -1 && curcuitJ == -1 {
-		curcuits = append(curcuits, []int{i, j})
-	} else if curcuitI != -1 && curcuitJ == -1 {
-		curcuits[curcuitI] = append(curcuits[curcuitI], j)
-	} else if curcuitI == -1 && curcuitJ != -1 {
-		curcuits[curcuitJ] = append(curcuits[curcuitJ], i)
-	} else if curcuitI != curcuitJ {
-		curcuits[curcuitI] = append(curcuits[curcuitI], curcuits[curcuitJ]...)
-		curcuits = append(curcuits[:curcuitJ], curcuits[curcuitJ+1:]...)
-	}
-
-	return curcuits
-}
-
-func areAllConnected(curcuits [][]int, totalPoints int) bool {
-	if len(curcuits) != 1 {
-		return false
-	}
-	if len(curcuits[0]) != totalPoints {
-		return false
-	}
-	return true
+func areAllConnected(circuits *unionfind.UnionFind) bool {
+	return circuits.Count() == 1
 }
 
 func run(input []string) int {
 	points := getPoints(input)
 	dists := getDistances(points)
-	curcuits := [][]int{}
+	circuits := unionfind.New(len(points))
 
 	res := 0
 
 	for {
 		minI, minJ := -1, -1
 		minI, minJ, dists = getMinDist(dists)
-		curcuits = connectPoints(curcuits, minI, minJ)
-		if areAllConnected(curcuits, len(points)) {
+		circuits.Union(minI, minJ)
+		if areAllConnected(circuits) {
 			res = points[minI].x * points[minJ].x
 			break
 		}
@@ -194,6 +149,8 @@ func main() {
 		"984,92,344",
 		"425,690,689",
 	}, 25272) && success
+	success = test([]string{"1,0,0", "5,0,0"}, 5) && success
+	success = test([]string{"0,0,0", "10,0,0", "3,0,0"}, 30) && success
 
 	if success {
 		fmt.Printf("-=-=-=-=-=-=-=-=-=-=-=-=-\n✅All tests passed!\n")
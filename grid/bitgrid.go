@@ -0,0 +1,152 @@
+package grid
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/bits"
+	"strings"
+)
+
+// BitGrid packs boolean cells into 64-bit words, row by row.
+// Bit x%64 of word x/64 is the cell x, bits past the width are always zero.
+type BitGrid struct {
+	width       int
+	height      int
+	wordsPerRow int
+	words       []uint64
+}
+
+func NewBits(width, height int) *BitGrid {
+	wordsPerRow := (width + 63) / 64
+	return &BitGrid{
+		width:       width,
+		height:      height,
+		wordsPerRow: wordsPerRow,
+		words:       make([]uint64, wordsPerRow*height),
+	}
+}
+
+func (g *BitGrid) appendLine(line string, isSet func(byte) bool) error {
+	if g.height == 0 && g.width == 0 {
+		g.width = len(line)
+		g.wordsPerRow = (g.width + 63) / 64
+	}
+	if len(line) != g.width {
+		return fmt.Errorf("inconsistent line length at line %d: expected %d, got %d", g.height, g.width, len(line))
+	}
+
+	row := make([]uint64, g.wordsPerRow)
+	for x := 0; x < len(line); x++ {
+		if isSet(line[x]) {
+			row[x/64] |= 1 << (x % 64)
+		}
+	}
+	g.words = append(g.words, row...)
+	g.height++
+	return nil
+}
+
+func ParseBits(lines []string, isSet func(byte) bool) (*BitGrid, error) {
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	g := &BitGrid{}
+	for _, line := range lines {
+		err := g.appendLine(line, isSet)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// ReadBits packs the lines as they are read, so the text is never held in memory as a whole.
+// Empty lines are held back until a non-empty line follows, so trailing ones are dropped as in ParseBits.
+func ReadBits(r io.Reader, isSet func(byte) bool) (*BitGrid, error) {
+	g := &BitGrid{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024*1024)
+	emptyLines := 0
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			emptyLines++
+			continue
+		}
+		for ; emptyLines > 0; emptyLines-- {
+			err := g.appendLine("", isSet)
+			if err != nil {
+				return nil, err
+			}
+		}
+		err := g.appendLine(line, isSet)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g *BitGrid) Width() int {
+	return g.width
+}
+
+func (g *BitGrid) Height() int {
+	return g.height
+}
+
+func (g *BitGrid) WordsPerRow() int {
+	return g.wordsPerRow
+}
+
+func (g *BitGrid) InBounds(x, y int) bool {
+	return x >= 0 && x < g.width && y >= 0 && y < g.height
+}
+
+// Get returns false when (x, y) is out of bounds
+func (g *BitGrid) Get(x, y int) bool {
+	if !g.InBounds(x, y) {
+		return false
+	}
+	return g.words[y*g.wordsPerRow+x/64]&(1<<(x%64)) != 0
+}
+
+// Set returns false and leaves the grid untouched when (x, y) is out of bounds
+func (g *BitGrid) Set(x, y int, value bool) bool {
+	if !g.InBounds(x, y) {
+		return false
+	}
+	if value {
+		g.words[y*g.wordsPerRow+x/64] |= 1 << (x % 64)
+	} else {
+		g.words[y*g.wordsPerRow+x/64] &^= 1 << (x % 64)
+	}
+	return true
+}
+
+// Row returns the words of row y without copying, or nil when y is out of bounds
+func (g *BitGrid) Row(y int) []uint64 {
+	if y < 0 || y >= g.height {
+		return nil
+	}
+	return g.words[y*g.wordsPerRow : (y+1)*g.wordsPerRow]
+}
+
+func (g *BitGrid) Count() int {
+	count := 0
+	for _, word := range g.words {
+		count += bits.OnesCount64(word)
+	}
+	return count
+}
+
+func (g *BitGrid) Clone() *BitGrid {
+	clone := *g
+	clone.words = append([]uint64{}, g.words...)
+	return &clone
+}
@@ -0,0 +1,199 @@
+package grid
+
+import (
+	"fmt"
+	"iter"
+	"strings"
+)
+
+type Point struct {
+	X int
+	Y int
+}
+
+var VonNeumann = []Point{
+	{0, -1},
+	{-1, 0}, {1, 0},
+	{0, 1}}
+
+var Moore = []Point{
+	{-1, -1}, {0, -1}, {1, -1},
+	{-1, 0}, {1, 0},
+	{-1, 1}, {0, 1}, {1, 1}}
+
+// Hexagonal uses axial coordinates: every other row is not shifted, the axes are skewed instead
+var Hexagonal = []Point{
+	{0, -1}, {1, -1},
+	{-1, 0}, {1, 0},
+	{-1, 1}, {0, 1}}
+
+// ParseKernel reads an odd-sized square of '#' and '.' around its center cell into offsets
+func ParseKernel(lines []string) ([]Point, error) {
+	size := len(lines)
+	if size%2 == 0 {
+		return nil, fmt.Errorf("kernel size must be odd, got %d", size)
+	}
+
+	offsets := []Point{}
+	center := size / 2
+	for y, line := range lines {
+		if len(line) != size {
+			return nil, fmt.Errorf("kernel line %d must have length %d, got %d", y, size, len(line))
+		}
+		for x := 0; x < size; x++ {
+			if line[x] != '#' {
+				continue
+			}
+			if x == center && y == center {
+				return nil, fmt.Errorf("kernel center cannot be a neighbor")
+			}
+			offsets = append(offsets, Point{x - center, y - center})
+		}
+	}
+	return offsets, nil
+}
+
+type Topology int
+
+const (
+	Bounded Topology = iota
+	Toroidal
+)
+
+// Grid stores cells row by row in a single slice, so access and mutation are O(1)
+type Grid[T any] struct {
+	width  int
+	height int
+	cells  []T
+}
+
+func New[T any](width, height int, fill T) *Grid[T] {
+	cells := make([]T, width*height)
+	for i := range cells {
+		cells[i] = fill
+	}
+	return &Grid[T]{width: width, height: height, cells: cells}
+}
+
+// Parse builds a grid from equally long lines, converting every byte with parseCell.
+// Trailing empty lines, as left by a final newline, are dropped.
+func Parse[T any](lines []string, parseCell func(byte) T) (*Grid[T], error) {
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return &Grid[T]{}, nil
+	}
+
+	width := len(lines[0])
+	g := &Grid[T]{width: width, height: len(lines), cells: make([]T, 0, width*len(lines))}
+	for y, line := range lines {
+		if len(line) != width {
+			return nil, fmt.Errorf("inconsistent line length at line %d: expected %d, got %d", y, width, len(line))
+		}
+		for x := 0; x < width; x++ {
+			g.cells = append(g.cells, parseCell(line[x]))
+		}
+	}
+	return g, nil
+}
+
+func (g *Grid[T]) Width() int {
+	return g.width
+}
+
+func (g *Grid[T]) Height() int {
+	return g.height
+}
+
+func (g *Grid[T]) InBounds(x, y int) bool {
+	return x >= 0 && x < g.width && y >= 0 && y < g.height
+}
+
+// Get returns the cell at (x, y) and false when it is out of bounds
+func (g *Grid[T]) Get(x, y int) (T, bool) {
+	if !g.InBounds(x, y) {
+		var zero T
+		return zero, false
+	}
+	return g.cells[y*g.width+x], true
+}
+
+// At returns the cell at (x, y) or the zero value when it is out of bounds
+func (g *Grid[T]) At(x, y int) T {
+	value, _ := g.Get(x, y)
+	return value
+}
+
+// Set returns false and leaves the grid untouched when (x, y) is out of bounds
+func (g *Grid[T]) Set(x, y int, value T) bool {
+	if !g.InBounds(x, y) {
+		return false
+	}
+	g.cells[y*g.width+x] = value
+	return true
+}
+
+func (g *Grid[T]) Clone() *Grid[T] {
+	cells := make([]T, len(g.cells))
+	copy(cells, g.cells)
+	return &Grid[T]{width: g.width, height: g.height, cells: cells}
+}
+
+func (g *Grid[T]) All() iter.Seq2[Point, T] {
+	return func(yield func(Point, T) bool) {
+		for y := 0; y < g.height; y++ {
+			for x := 0; x < g.width; x++ {
+				if !yield(Point{x, y}, g.cells[y*g.width+x]) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Neighbors yields the in-bounds cells at the given offsets from (x, y)
+func (g *Grid[T]) Neighbors(x, y int, offsets []Point) iter.Seq2[Point, T] {
+	return g.NeighborsIn(Bounded, x, y, offsets)
+}
+
+// NeighborsIn yields the cells at the given offsets from (x, y).
+// On a toroidal grid every offset yields a cell, so grids narrower than
+// the neighborhood yield the same cell more than once.
+func (g *Grid[T]) NeighborsIn(topology Topology, x, y int, offsets []Point) iter.Seq2[Point, T] {
+	return func(yield func(Point, T) bool) {
+		if len(g.cells) == 0 {
+			return
+		}
+		for _, offset := range offsets {
+			nx := x + offset.X
+			ny := y + offset.Y
+			if topology == Toroidal {
+				nx = ((nx % g.width) + g.width) % g.width
+				ny = ((ny % g.height) + g.height) % g.height
+			}
+			if !g.InBounds(nx, ny) {
+				continue
+			}
+			if !yield(Point{nx, ny}, g.cells[ny*g.width+nx]) {
+				return
+			}
+		}
+	}
+}
+
+func (g *Grid[T]) Lines(renderCell func(T) byte) []string {
+	lines := make([]string, g.height)
+	row := make([]byte, g.width)
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			row[x] = renderCell(g.cells[y*g.width+x])
+		}
+		lines[y] = string(row)
+	}
+	return lines
+}
+
+func (g *Grid[T]) Render(renderCell func(T) byte) string {
+	return strings.Join(g.Lines(renderCell), "\n")
+}
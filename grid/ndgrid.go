@@ -0,0 +1,212 @@
+package grid
+
+import (
+	"fmt"
+	"iter"
+)
+
+// NGrid is an N-dimensional grid; coordinates go x, y, z, w...
+// and cells are addressed by their flat index.
+type NGrid[T any] struct {
+	dims    []int
+	strides []int
+	cells   []T
+}
+
+func NewN[T any](dims []int, fill T) *NGrid[T] {
+	g := &NGrid[T]{dims: append([]int{}, dims...), strides: make([]int, len(dims))}
+	size := 1
+	for d, dim := range dims {
+		g.strides[d] = size
+		size *= dim
+	}
+	g.cells = make([]T, size)
+	for i := range g.cells {
+		g.cells[i] = fill
+	}
+	return g
+}
+
+// MooreN returns the 3^n-1 offsets around a cell in n dimensions
+func MooreN(n int) [][]int {
+	offsets := [][]int{{}}
+	for d := 0; d < n; d++ {
+		next := [][]int{}
+		for _, offset := range offsets {
+			for delta := -1; delta <= 1; delta++ {
+				next = append(next, append(append([]int{}, offset...), delta))
+			}
+		}
+		offsets = next
+	}
+
+	neighbors := [][]int{}
+	for _, offset := range offsets {
+		for _, delta := range offset {
+			if delta != 0 {
+				neighbors = append(neighbors, offset)
+				break
+			}
+		}
+	}
+	return neighbors
+}
+
+func splitOnBlankRuns(lines []string, runLength int) [][]string {
+	blocks := [][]string{{}}
+	blanks := 0
+	for _, line := range lines {
+		if line == "" {
+			blanks++
+			continue
+		}
+		if blanks == runLength {
+			blocks = append(blocks, []string{})
+		} else {
+			for ; blanks > 0; blanks-- {
+				blocks[len(blocks)-1] = append(blocks[len(blocks)-1], "")
+			}
+		}
+		blanks = 0
+		blocks[len(blocks)-1] = append(blocks[len(blocks)-1], line)
+	}
+	return blocks
+}
+
+func parseBlock[T any](lines []string, level int, parseCell func(byte) T) ([]int, []T, error) {
+	if level == 0 {
+		g, err := Parse(lines, parseCell)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []int{g.Width(), g.Height()}, g.cells, nil
+	}
+
+	var dims []int
+	cells := []T{}
+	for ind, block := range splitOnBlankRuns(lines, level) {
+		blockDims, blockCells, err := parseBlock(block, level-1, parseCell)
+		if err != nil {
+			return nil, nil, err
+		}
+		if dims == nil {
+			dims = blockDims
+		} else if fmt.Sprint(dims) != fmt.Sprint(blockDims) {
+			return nil, nil, fmt.Errorf("inconsistent block %d at level %d: expected %v, got %v", ind, level, dims, blockDims)
+		}
+		cells = append(cells, blockCells...)
+	}
+	return append(dims, len(cells)/max(1, product(dims))), cells, nil
+}
+
+func product(values []int) int {
+	result := 1
+	for _, value := range values {
+		result *= value
+	}
+	return result
+}
+
+// ParseLayers reads 2D layers separated by blank lines.
+// One blank line separates z-layers, two blank lines separate w-blocks of z-layers and so on,
+// so the longest run of blank lines defines the number of dimensions.
+func ParseLayers[T any](lines []string, parseCell func(byte) T) (*NGrid[T], error) {
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	maxRun := 0
+	run := 0
+	for _, line := range lines {
+		if line == "" {
+			run++
+			maxRun = max(maxRun, run)
+		} else {
+			run = 0
+		}
+	}
+
+	dims, cells, err := parseBlock(lines, maxRun, parseCell)
+	if err != nil {
+		return nil, err
+	}
+
+	g := NewN(dims, *new(T))
+	copy(g.cells, cells)
+	return g, nil
+}
+
+func (g *NGrid[T]) Dims() []int {
+	return append([]int{}, g.dims...)
+}
+
+func (g *NGrid[T]) Len() int {
+	return len(g.cells)
+}
+
+func (g *NGrid[T]) Coords(index int) []int {
+	coords := make([]int, len(g.dims))
+	for d := range g.dims {
+		coords[d] = index / g.strides[d] % g.dims[d]
+	}
+	return coords
+}
+
+// Index returns false when the coordinates are out of bounds
+func (g *NGrid[T]) Index(coords []int) (int, bool) {
+	if len(coords) != len(g.dims) {
+		return 0, false
+	}
+	index := 0
+	for d, c := range coords {
+		if c < 0 || c >= g.dims[d] {
+			return 0, false
+		}
+		index += c * g.strides[d]
+	}
+	return index, true
+}
+
+func (g *NGrid[T]) At(index int) T {
+	return g.cells[index]
+}
+
+func (g *NGrid[T]) Set(index int, value T) {
+	g.cells[index] = value
+}
+
+func (g *NGrid[T]) Clone() *NGrid[T] {
+	clone := NewN(g.dims, *new(T))
+	copy(clone.cells, g.cells)
+	return clone
+}
+
+func (g *NGrid[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		for index, value := range g.cells {
+			if !yield(index, value) {
+				return
+			}
+		}
+	}
+}
+
+// Neighbors yields the in-bounds cells at the given offsets from the cell at index
+func (g *NGrid[T]) Neighbors(index int, offsets [][]int) iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		coords := g.Coords(index)
+		neighbor := make([]int, len(coords))
+		for _, offset := range offsets {
+			for d := range coords {
+				neighbor[d] = coords[d] + offset[d]
+			}
+			nIndex, ok := g.Index(neighbor)
+			if !ok {
+				continue
+			}
+			if !yield(nIndex, g.cells[nIndex]) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package grid
+
+import "fmt"
+
+type Comparison int
+
+const (
+	Less Comparison = iota
+	LessOrEqual
+	Equal
+	NotEqual
+	GreaterOrEqual
+	Greater
+)
+
+func (c Comparison) Holds(value, threshold int) bool {
+	switch c {
+	case Less:
+		return value < threshold
+	case LessOrEqual:
+		return value <= threshold
+	case Equal:
+		return value == threshold
+	case NotEqual:
+		return value != threshold
+	case GreaterOrEqual:
+		return value >= threshold
+	case Greater:
+		return value > threshold
+	}
+	panic(fmt.Sprintf("Unknown comparison: %d", c))
+}
+
+// Rule holds for a cell when "neighbors <Comparison> Threshold" holds,
+// counting the neighbors at the Neighborhood offsets under Topology
+type Rule struct {
+	Neighborhood []Point
+	Topology     Topology
+	Comparison   Comparison
+	Threshold    int
+}
+
+func (r Rule) Holds(neighbors int) bool {
+	return r.Comparison.Holds(neighbors, r.Threshold)
+}
+
+// Negate flips every offset, the cells whose neighborhood contains (x, y)
+// are the ones at the negated offsets from (x, y)
+func Negate(offsets []Point) []Point {
+	negated := make([]Point, len(offsets))
+	for i, offset := range offsets {
+		negated[i] = Point{-offset.X, -offset.Y}
+	}
+	return negated
+}
@@ -0,0 +1,149 @@
+package intervalset
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Interval is inclusive on both ends
+type Interval struct {
+	Start int
+	End   int
+}
+
+func (i Interval) Len() int {
+	return i.End - i.Start + 1
+}
+
+func (i Interval) Contains(value int) bool {
+	return value >= i.Start && value <= i.End
+}
+
+func (i Interval) String() string {
+	return fmt.Sprintf("%d-%d", i.Start, i.End)
+}
+
+// IntervalSet keeps its intervals sorted, disjoint and non-adjacent
+type IntervalSet struct {
+	intervals []Interval
+}
+
+// New normalizes the intervals, empty ones (Start > End) are dropped
+func New(intervals ...Interval) *IntervalSet {
+	sorted := []Interval{}
+	for _, i := range intervals {
+		if i.Start <= i.End {
+			sorted = append(sorted, i)
+		}
+	}
+	sort.Slice(sorted, func(a, b int) bool {
+		return sorted[a].Start < sorted[b].Start
+	})
+
+	merged := []Interval{}
+	for _, i := range sorted {
+		last := len(merged) - 1
+		if last >= 0 && i.Start <= merged[last].End+1 {
+			merged[last].End = max(merged[last].End, i.End)
+		} else {
+			merged = append(merged, i)
+		}
+	}
+	return &IntervalSet{intervals: merged}
+}
+
+func (s *IntervalSet) Intervals() []Interval {
+	return append([]Interval{}, s.intervals...)
+}
+
+func (s *IntervalSet) IsEmpty() bool {
+	return len(s.intervals) == 0
+}
+
+// Find returns the interval containing value using binary search
+func (s *IntervalSet) Find(value int) (Interval, bool) {
+	ind := sort.Search(len(s.intervals), func(i int) bool {
+		return s.intervals[i].End >= value
+	})
+	if ind < len(s.intervals) && s.intervals[ind].Start <= value {
+		return s.intervals[ind], true
+	}
+	return Interval{}, false
+}
+
+func (s *IntervalSet) Contains(value int) bool {
+	_, ok := s.Find(value)
+	return ok
+}
+
+// Len is the total number of covered values
+func (s *IntervalSet) Len() int {
+	total := 0
+	for _, i := range s.intervals {
+		total += i.Len()
+	}
+	return total
+}
+
+func (s *IntervalSet) Union(other *IntervalSet) *IntervalSet {
+	return New(append(s.Intervals(), other.intervals...)...)
+}
+
+func (s *IntervalSet) Intersect(other *IntervalSet) *IntervalSet {
+	result := []Interval{}
+	a, b := 0, 0
+	for a < len(s.intervals) && b < len(other.intervals) {
+		ia := s.intervals[a]
+		ib := other.intervals[b]
+		start := max(ia.Start, ib.Start)
+		end := min(ia.End, ib.End)
+		if start <= end {
+			result = append(result, Interval{Start: start, End: end})
+		}
+		if ia.End < ib.End {
+			a++
+		} else {
+			b++
+		}
+	}
+	return &IntervalSet{intervals: result}
+}
+
+// Complement returns the values within bounds that are not in the set
+func (s *IntervalSet) Complement(bounds Interval) *IntervalSet {
+	result := []Interval{}
+	next := bounds.Start
+	for _, i := range s.intervals {
+		if i.End < bounds.Start {
+			continue
+		}
+		if i.Start > bounds.End {
+			break
+		}
+		if i.Start > next {
+			result = append(result, Interval{Start: next, End: i.Start - 1})
+		}
+		next = i.End + 1
+	}
+	if next <= bounds.End {
+		result = append(result, Interval{Start: next, End: bounds.End})
+	}
+	return &IntervalSet{intervals: result}
+}
+
+func (s *IntervalSet) Difference(other *IntervalSet) *IntervalSet {
+	if s.IsEmpty() {
+		return New()
+	}
+	bounds := Interval{Start: s.intervals[0].Start, End: s.intervals[len(s.intervals)-1].End}
+	return s.Intersect(other.Complement(bounds))
+}
+
+func (s *IntervalSet) String() string {
+	parts := []string{}
+	for _, i := range s.intervals {
+		parts = append(parts, i.String())
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
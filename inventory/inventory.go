@@ -0,0 +1,120 @@
+package inventory
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Range is inclusive on both ends
+type Range struct {
+	Start int
+	End   int
+}
+
+type ParseError struct {
+	Line    int // 1-based
+	Message string
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// Database is a file of fresh ID ranges, a blank line, then the available IDs
+type Database struct {
+	Ranges []Range
+	Ids    []int
+}
+
+func parseRange(line string) (Range, bool) {
+	startStr, endStr, found := strings.Cut(line, "-")
+	if !found {
+		return Range{}, false
+	}
+	start, errStart := strconv.Atoi(strings.TrimSpace(startStr))
+	end, errEnd := strconv.Atoi(strings.TrimSpace(endStr))
+	if errStart != nil || errEnd != nil {
+		return Range{}, false
+	}
+	return Range{Start: start, End: end}, true
+}
+
+func parseId(line string) (int, bool) {
+	id, err := strconv.Atoi(strings.TrimSpace(line))
+	return id, err == nil
+}
+
+// Parse reports every problem with its line number.
+// In strict mode the database is only usable when there are no problems,
+// in lenient mode the problems are repaired and describe the repair.
+func Parse(lines []string, lenient bool) (Database, []ParseError) {
+	db := Database{}
+	problems := []ParseError{}
+	report := func(line int, message, repair string) {
+		if lenient {
+			message += ", " + repair
+		}
+		problems = append(problems, ParseError{Line: line + 1, Message: message})
+	}
+
+	readingIds := false
+	for ind, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			readingIds = true
+			continue
+		}
+
+		if !readingIds {
+			if r, ok := parseRange(line); ok {
+				if r.Start > r.End {
+					report(ind, fmt.Sprintf("reversed range %q", line), fmt.Sprintf("swapped to %d-%d", r.End, r.Start))
+					r.Start, r.End = r.End, r.Start
+				}
+				db.Ranges = append(db.Ranges, r)
+				continue
+			}
+			if _, ok := parseId(line); !ok {
+				report(ind, fmt.Sprintf("invalid range %q", line), "skipped")
+				continue
+			}
+			report(ind, "missing blank line before IDs", "assumed here")
+			readingIds = true
+		}
+
+		id, ok := parseId(line)
+		if !ok {
+			report(ind, fmt.Sprintf("invalid ID %q", line), "skipped")
+			continue
+		}
+		db.Ids = append(db.Ids, id)
+	}
+
+	return db, problems
+}
+
+// MustParse panics with every problem when the lines are not a valid database
+func MustParse(lines []string) Database {
+	db, problems := Parse(lines, false)
+	if len(problems) > 0 {
+		messages := []string{}
+		for _, p := range problems {
+			messages = append(messages, p.Error())
+		}
+		panic("Invalid database:\n" + strings.Join(messages, "\n"))
+	}
+	return db
+}
+
+// Format writes the database back in the file format, Parse(Format(db)) gives db again
+func Format(db Database) []string {
+	lines := []string{}
+	for _, r := range db.Ranges {
+		lines = append(lines, fmt.Sprintf("%d-%d", r.Start, r.End))
+	}
+	lines = append(lines, "")
+	for _, id := range db.Ids {
+		lines = append(lines, fmt.Sprintf("%d", id))
+	}
+	return lines
+}
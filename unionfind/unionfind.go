@@ -0,0 +1,118 @@
+package unionfind
+
+import (
+	"slices"
+	"sort"
+)
+
+// UnionFind keeps the elements 0..n-1 in disjoint sets, with path compression and union by size
+type UnionFind struct {
+	parents []int
+	sizes   []int // only up to date for roots
+	count   int
+
+	// distinct set sizes in increasing order and how many sets have each of them,
+	// there are at most O(sqrt(n)) of them since the sizes add up to n
+	bySize     []int
+	sizeCounts map[int]int
+}
+
+// New puts every element in a set of its own
+func New(n int) *UnionFind {
+	u := &UnionFind{
+		parents:    make([]int, n),
+		sizes:      make([]int, n),
+		count:      n,
+		bySize:     []int{},
+		sizeCounts: map[int]int{},
+	}
+	for i := range n {
+		u.parents[i] = i
+		u.sizes[i] = 1
+	}
+	if n > 0 {
+		u.bySize = append(u.bySize, 1)
+		u.sizeCounts[1] = n
+	}
+	return u
+}
+
+func (u *UnionFind) Len() int {
+	return len(u.parents)
+}
+
+// Count is the number of sets
+func (u *UnionFind) Count() int {
+	return u.count
+}
+
+// Find returns the root of x's set and points every element on the way directly at it
+func (u *UnionFind) Find(x int) int {
+	root := x
+	for u.parents[root] != root {
+		root = u.parents[root]
+	}
+	for x != root {
+		next := u.parents[x]
+		u.parents[x] = root
+		x = next
+	}
+	return root
+}
+
+func (u *UnionFind) Connected(a, b int) bool {
+	return u.Find(a) == u.Find(b)
+}
+
+// Size is the size of x's set
+func (u *UnionFind) Size(x int) int {
+	return u.sizes[u.Find(x)]
+}
+
+// Union merges the sets of a and b, it returns false when they were already the same set
+func (u *UnionFind) Union(a, b int) bool {
+	rootA, rootB := u.Find(a), u.Find(b)
+	if rootA == rootB {
+		return false
+	}
+	if u.sizes[rootA] < u.sizes[rootB] {
+		rootA, rootB = rootB, rootA
+	}
+
+	u.removeSize(u.sizes[rootA])
+	u.removeSize(u.sizes[rootB])
+	u.parents[rootB] = rootA
+	u.sizes[rootA] += u.sizes[rootB]
+	u.addSize(u.sizes[rootA])
+	u.count--
+	return true
+}
+
+func (u *UnionFind) addSize(size int) {
+	if u.sizeCounts[size] == 0 {
+		ind := sort.SearchInts(u.bySize, size)
+		u.bySize = slices.Insert(u.bySize, ind, size)
+	}
+	u.sizeCounts[size]++
+}
+
+func (u *UnionFind) removeSize(size int) {
+	u.sizeCounts[size]--
+	if u.sizeCounts[size] == 0 {
+		delete(u.sizeCounts, size)
+		ind := sort.SearchInts(u.bySize, size)
+		u.bySize = slices.Delete(u.bySize, ind, ind+1)
+	}
+}
+
+// Largest returns the sizes of the k biggest sets in decreasing order, fewer when there are not k sets
+func (u *UnionFind) Largest(k int) []int {
+	largest := []int{}
+	for ind := len(u.bySize) - 1; ind >= 0 && len(largest) < k; ind-- {
+		size := u.bySize[ind]
+		for range min(u.sizeCounts[size], k-len(largest)) {
+			largest = append(largest, size)
+		}
+	}
+	return largest
+}
@@ -0,0 +1,166 @@
+package worksheet
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Equation writes a problem the conventional way, e.g. "123 * 45 * 6 = 33210"
+// or "gcd(12, 18) = 6" for operators that are words
+func Equation(p Problem, result Number) string {
+	numbers := []string{}
+	for _, n := range p.Numbers {
+		numbers = append(numbers, n.String())
+	}
+
+	if p.Operator != "" && unicode.IsLetter(rune(p.Operator[0])) {
+		return fmt.Sprintf("%s(%s) = %v", p.Operator, strings.Join(numbers, ", "), result)
+	}
+	if len(numbers) == 0 {
+		return fmt.Sprintf("%v", result)
+	}
+	return fmt.Sprintf("%s = %v", strings.Join(numbers, " "+p.Operator+" "), result)
+}
+
+// Explain describes every problem with its position in the sheet
+func Explain(problems []Problem, alwaysBig bool) ([]string, error) {
+	lines := []string{}
+	for ind, p := range problems {
+		result, err := EvaluateProblem(p, alwaysBig)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, fmt.Sprintf("Problem %d, columns %d-%d: %s", ind+1, p.Column+1, p.Column+p.Width, Equation(p, result)))
+	}
+	return lines, nil
+}
+
+func WriteCSV(w io.Writer, problems []Problem, alwaysBig bool) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"problem", "column", "width", "operator", "numbers", "result"})
+	if err != nil {
+		return err
+	}
+
+	for ind, p := range problems {
+		result, err := EvaluateProblem(p, alwaysBig)
+		if err != nil {
+			return err
+		}
+
+		numbers := []string{}
+		for _, n := range p.Numbers {
+			numbers = append(numbers, n.String())
+		}
+		err = writer.Write([]string{
+			strconv.Itoa(ind + 1),
+			strconv.Itoa(p.Column + 1),
+			strconv.Itoa(p.Width),
+			p.Operator,
+			strings.Join(numbers, " "),
+			result.String(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func reverseString(s string) string {
+	b := []byte(s)
+	reverse(b)
+	return string(b)
+}
+
+// renderBlock lays out the numbers of one problem so that readNumbers reads them back in order
+func renderBlock(numbers []Number, order ReadingOrder) []string {
+	digitLines := []string{}
+	length := 0
+	for _, n := range numbers {
+		digits := n.String()
+		digitLines = append(digitLines, digits)
+		length = max(length, len(digits))
+	}
+	for i, digits := range digitLines {
+		digitLines[i] = digits + strings.Repeat(" ", length-len(digits))
+	}
+
+	if !order.Columns {
+		rows := []string{}
+		for _, digits := range digitLines {
+			if order.RightToLeft {
+				digits = reverseString(digits)
+			}
+			rows = append(rows, digits)
+		}
+		if order.BottomUp {
+			reverse(rows)
+		}
+		return rows
+	}
+
+	columns := []string{}
+	for _, digits := range digitLines {
+		if order.BottomUp {
+			digits = reverseString(digits)
+		}
+		columns = append(columns, digits)
+	}
+	if order.RightToLeft {
+		reverse(columns)
+	}
+
+	rows := []string{}
+	for y := 0; y < length; y++ {
+		row := []byte{}
+		for _, column := range columns {
+			row = append(row, column[y])
+		}
+		rows = append(rows, string(row))
+	}
+	return rows
+}
+
+// Render is the inverse of Parse: it lays the problems out as a worksheet
+// read in the given order, with one blank column between problems
+func Render(problems []Problem, order ReadingOrder) []string {
+	blocks := [][]string{}
+	height := 0
+	for _, p := range problems {
+		block := renderBlock(p.Numbers, order)
+		blocks = append(blocks, block)
+		height = max(height, len(block))
+	}
+
+	lines := make([]string, height+1)
+	for ind, p := range problems {
+		block := blocks[ind]
+		width := len(p.Operator)
+		if len(block) > 0 {
+			width = max(width, len(block[0]))
+		}
+
+		for y := 0; y < height; y++ {
+			row := ""
+			if y < len(block) {
+				row = block[y]
+			}
+			if ind > 0 {
+				lines[y] += " "
+			}
+			lines[y] += row + strings.Repeat(" ", width-len(row))
+		}
+		if ind > 0 {
+			lines[height] += " "
+		}
+		lines[height] += p.Operator + strings.Repeat(" ", width-len(p.Operator))
+	}
+	return lines
+}
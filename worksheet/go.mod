@@ -0,0 +1,3 @@
+module worksheet
+
+go 1.25.4
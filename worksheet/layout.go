@@ -0,0 +1,298 @@
+package worksheet
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ReadingOrder says how digits inside a problem form numbers.
+// By default every row is a number read left to right, with numbers taken top to bottom.
+// With Columns every column is a number read top to bottom, with numbers taken left to right.
+// RightToLeft and BottomUp reverse the horizontal and the vertical direction.
+type ReadingOrder struct {
+	Columns     bool
+	RightToLeft bool
+	BottomUp    bool
+}
+
+var RowMajor = ReadingOrder{}
+var ColumnMajor = ReadingOrder{Columns: true}
+
+type Problem struct {
+	Operator       string
+	OperatorColumn int // 0-based
+	Numbers        []Number
+	Column         int // 0-based column of the first character of the problem
+	Width          int
+}
+
+type Options struct {
+	Order    ReadingOrder
+	TabWidth int // tabs move to the next multiple of TabWidth, 8 when not set
+}
+
+// Warning points at a 1-based line and/or column, 0 means it doesn't apply
+type Warning struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.Line > 0 && w.Column > 0 {
+		return fmt.Sprintf("line %d, column %d: %s", w.Line, w.Column, w.Message)
+	}
+	if w.Line > 0 {
+		return fmt.Sprintf("line %d: %s", w.Line, w.Message)
+	}
+	return fmt.Sprintf("column %d: %s", w.Column, w.Message)
+}
+
+func expandTabs(line string, tabWidth int) string {
+	var sb strings.Builder
+	col := 0
+	for i := 0; i < len(line); i++ {
+		if line[i] != '\t' {
+			sb.WriteByte(line[i])
+			col++
+			continue
+		}
+		spaces := tabWidth - col%tabWidth
+		sb.WriteString(strings.Repeat(" ", spaces))
+		col += spaces
+	}
+	return sb.String()
+}
+
+func isOperatorRow(line string) bool {
+	return strings.TrimSpace(line) != "" && !strings.ContainsAny(line, "0123456789")
+}
+
+func padLines(lines []string) []string {
+	width := 0
+	for _, line := range lines {
+		width = max(width, len(line))
+	}
+
+	padded := make([]string, len(lines))
+	for i, line := range lines {
+		padded[i] = line + strings.Repeat(" ", width-len(line))
+	}
+	return padded
+}
+
+func isEmptyCol(lines []string, col int) bool {
+	for _, line := range lines {
+		if line[col] != ' ' {
+			return false
+		}
+	}
+	return true
+}
+
+// block returns the characters of the problem between columns [start, end) of the number rows
+func block(numberRows []string, start, end int) [][]byte {
+	cells := [][]byte{}
+	for _, row := range numberRows {
+		cells = append(cells, []byte(row[start:end]))
+	}
+	return cells
+}
+
+func reverse[T any](values []T) {
+	for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+		values[i], values[j] = values[j], values[i]
+	}
+}
+
+// digitLine is one number before parsing, line or column tell where it was read from
+type digitLine struct {
+	digits string
+	line   int
+	column int
+}
+
+func readNumbers(cells [][]byte, lineNumbers []int, order ReadingOrder, column int) ([]Number, []Warning, error) {
+	height := len(cells)
+	width := 0
+	if height > 0 {
+		width = len(cells[0])
+	}
+
+	digitLines := []digitLine{}
+	if order.Columns {
+		for x := 0; x < width; x++ {
+			digits := []byte{}
+			for y := 0; y < height; y++ {
+				digits = append(digits, cells[y][x])
+			}
+			if order.BottomUp {
+				reverse(digits)
+			}
+			digitLines = append(digitLines, digitLine{digits: string(digits), column: column + x + 1})
+		}
+		if order.RightToLeft {
+			reverse(digitLines)
+		}
+	} else {
+		for y := 0; y < height; y++ {
+			digits := append([]byte{}, cells[y]...)
+			if order.RightToLeft {
+				reverse(digits)
+			}
+			digitLines = append(digitLines, digitLine{digits: string(digits), line: lineNumbers[y]})
+		}
+		if order.BottomUp {
+			reverse(digitLines)
+		}
+	}
+
+	numbers := []Number{}
+	warnings := []Warning{}
+	for _, d := range digitLines {
+		trimmed := strings.TrimSpace(d.digits)
+		if trimmed == "" {
+			continue
+		}
+		if strings.Contains(trimmed, " ") {
+			warnings = append(warnings, Warning{Line: d.line, Column: d.column, Message: fmt.Sprintf("gap inside number %q", trimmed)})
+		}
+		digits := strings.ReplaceAll(trimmed, " ", "")
+		n, err := strconv.Atoi(digits)
+		if errors.Is(err, strconv.ErrRange) {
+			// too long for an int, but still a number
+			if b, ok := new(big.Int).SetString(digits, 10); ok {
+				numbers = append(numbers, Number{big: b})
+				continue
+			}
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid number %q in problem at column %d", digits, column+1)
+		}
+		numbers = append(numbers, Number{small: n})
+	}
+	return numbers, warnings, nil
+}
+
+// Parse is ParseTolerant with default options and without warnings
+func Parse(lines []string, order ReadingOrder) ([]Problem, error) {
+	problems, _, err := ParseTolerant(lines, Options{Order: order})
+	return problems, err
+}
+
+// ParseTolerant splits the worksheet into problems on columns that are blank in every line
+// and reads the numbers of each problem in the given order.
+// Tabs are expanded, blank lines are skipped and shorter lines are padded with spaces.
+// The operator row is the only line without digits, wherever it is.
+func ParseTolerant(lines []string, opts Options) ([]Problem, []Warning, error) {
+	tabWidth := opts.TabWidth
+	if tabWidth <= 0 {
+		tabWidth = 8
+	}
+
+	warnings := []Warning{}
+	sheet := []string{}
+	lineNumbers := []int{}
+	for ind, line := range lines {
+		if tab := strings.Index(line, "\t"); tab != -1 {
+			warnings = append(warnings, Warning{Line: ind + 1, Column: tab + 1, Message: fmt.Sprintf("tab expanded to width %d, alignment depends on it", tabWidth)})
+		}
+		line = expandTabs(strings.TrimSuffix(line, "\r"), tabWidth)
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		sheet = append(sheet, line)
+		lineNumbers = append(lineNumbers, ind+1)
+	}
+	if len(sheet) == 0 {
+		return nil, nil, fmt.Errorf("worksheet is empty")
+	}
+
+	operatorInd := -1
+	for ind, line := range sheet {
+		if !isOperatorRow(line) {
+			continue
+		}
+		if operatorInd != -1 {
+			return nil, nil, fmt.Errorf("operator row is ambiguous: lines %d and %d have no digits", lineNumbers[operatorInd], lineNumbers[ind])
+		}
+		operatorInd = ind
+	}
+	if operatorInd == -1 {
+		return nil, nil, fmt.Errorf("no operator row: every line has digits")
+	}
+
+	sheet = padLines(sheet)
+	operatorRow := sheet[operatorInd]
+	numberRows := append(append([]string{}, sheet[:operatorInd]...), sheet[operatorInd+1:]...)
+	numberLineNumbers := append(append([]int{}, lineNumbers[:operatorInd]...), lineNumbers[operatorInd+1:]...)
+
+	problems := []Problem{}
+	width := len(operatorRow)
+	for start := 0; start < width; {
+		if isEmptyCol(sheet, start) {
+			start++
+			continue
+		}
+		end := start
+		for end < width && !isEmptyCol(sheet, end) {
+			if len(numberRows) > 0 && isEmptyCol(numberRows, end) {
+				warnings = append(warnings, Warning{Column: end + 1, Message: "operator row is the only non-blank line in this column"})
+			}
+			end++
+		}
+
+		fields := strings.Fields(operatorRow[start:end])
+		if len(fields) != 1 {
+			return nil, nil, fmt.Errorf("expected one operator in problem at column %d, got %d", start+1, len(fields))
+		}
+
+		numbers, numberWarnings, err := readNumbers(block(numberRows, start, end), numberLineNumbers, opts.Order, start)
+		if err != nil {
+			return nil, nil, err
+		}
+		warnings = append(warnings, numberWarnings...)
+
+		problems = append(problems, Problem{
+			Operator:       fields[0],
+			OperatorColumn: start + strings.Index(operatorRow[start:end], fields[0]),
+			Numbers:        numbers,
+			Column:         start,
+			Width:          end - start,
+		})
+		start = end
+	}
+
+	return problems, warnings, nil
+}
+
+func EvaluateProblem(p Problem, alwaysBig bool) (Number, error) {
+	op, ok := operators[p.Operator]
+	if !ok {
+		return Number{}, fmt.Errorf("unknown operator %q at column %d", p.Operator, p.OperatorColumn+1)
+	}
+	result, err := op.fold(p.Numbers, alwaysBig)
+	if err != nil {
+		return Number{}, fmt.Errorf("%v at column %d", err, p.OperatorColumn+1)
+	}
+	return result, nil
+}
+
+// Evaluate sums the results of all problems, the sum also falls back to math/big on overflow
+func Evaluate(problems []Problem, alwaysBig bool) (Number, error) {
+	total := Number{}
+	if alwaysBig {
+		total = Number{big: big.NewInt(0)}
+	}
+	for _, p := range problems {
+		result, err := EvaluateProblem(p, alwaysBig)
+		if err != nil {
+			return Number{}, err
+		}
+		total = addNumbers(total, result, alwaysBig)
+	}
+	return total, nil
+}
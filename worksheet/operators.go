@@ -0,0 +1,291 @@
+package worksheet
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// ErrOverflow makes the evaluation fall back to math/big
+var ErrOverflow = errors.New("int overflow")
+
+// Number is an int until a computation overflows, then it is kept in math/big
+type Number struct {
+	small int
+	big   *big.Int
+}
+
+func (n Number) IsBig() bool {
+	return n.big != nil
+}
+
+// Int is only meaningful when the number is not big
+func (n Number) Int() int {
+	return n.small
+}
+
+func (n Number) Big() *big.Int {
+	return new(big.Int).Set(n.toBig())
+}
+
+func (n Number) toBig() *big.Int {
+	if n.big != nil {
+		return n.big
+	}
+	return big.NewInt(int64(n.small))
+}
+
+func (n Number) String() string {
+	if n.big != nil {
+		return n.big.String()
+	}
+	return strconv.Itoa(n.small)
+}
+
+func addNumbers(a, b Number, alwaysBig bool) Number {
+	if a.big == nil && b.big == nil && !alwaysBig {
+		sum, err := addInts(a.small, b.small)
+		if err == nil {
+			return Number{small: sum}
+		}
+	}
+	return Number{big: new(big.Int).Add(a.toBig(), b.toBig())}
+}
+
+func addInts(a, b int) (int, error) {
+	c := a + b
+	if (a > 0 && b > 0 && c < 0) || (a < 0 && b < 0 && c >= 0) {
+		return 0, ErrOverflow
+	}
+	return c, nil
+}
+
+func subInts(a, b int) (int, error) {
+	c := a - b
+	if (a >= 0 && b < 0 && c < 0) || (a < 0 && b > 0 && c >= 0) {
+		return 0, ErrOverflow
+	}
+	return c, nil
+}
+
+func mulInts(a, b int) (int, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+	c := a * b
+	if c/b != a || (a == -1 && b == math.MinInt) || (b == -1 && a == math.MinInt) {
+		return 0, ErrOverflow
+	}
+	return c, nil
+}
+
+// powInts computes a^b by squaring, the base is only squared while bits of b are left
+// so that the last squaring cannot overflow on its own
+func powInts(a, b int) (int, error) {
+	result := 1
+	for b > 0 {
+		var err error
+		if b&1 == 1 {
+			result, err = mulInts(result, a)
+			if err != nil {
+				return 0, err
+			}
+		}
+		b >>= 1
+		if b > 0 {
+			a, err = mulInts(a, a)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	return result, nil
+}
+
+type Operator struct {
+	Symbol      string
+	Apply       func(a, b int) (int, error) // returns ErrOverflow when the result doesn't fit in an int
+	ApplyBig    func(a, b *big.Int) (*big.Int, error)
+	Identity    int // result for a problem without numbers
+	HasIdentity bool
+}
+
+// fold applies the operator left to right: ((n0 op n1) op n2) ...
+// and redoes the whole fold in math/big as soon as an int overflows
+// or right away when an operand is already too big for an int
+func (op Operator) fold(numbers []Number, alwaysBig bool) (Number, error) {
+	if len(numbers) == 0 {
+		if !op.HasIdentity {
+			return Number{}, fmt.Errorf("operator %q needs at least one number", op.Symbol)
+		}
+		return addNumbers(Number{small: op.Identity}, Number{}, alwaysBig), nil
+	}
+
+	hasBig := false
+	for _, n := range numbers {
+		hasBig = hasBig || n.IsBig()
+	}
+
+	if !alwaysBig && !hasBig {
+		result := numbers[0].small
+		var err error
+		for _, n := range numbers[1:] {
+			result, err = op.Apply(result, n.small)
+			if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			return Number{small: result}, nil
+		}
+		if err != ErrOverflow {
+			return Number{}, err
+		}
+	}
+
+	result := numbers[0].Big()
+	for _, n := range numbers[1:] {
+		var err error
+		result, err = op.ApplyBig(result, n.toBig())
+		if err != nil {
+			return Number{}, err
+		}
+	}
+	return Number{big: result}, nil
+}
+
+var operators = map[string]Operator{}
+
+// Register adds an operator or replaces the one with the same symbol
+func Register(op Operator) {
+	operators[op.Symbol] = op
+}
+
+func gcd(a, b int) (int, error) {
+	if a == math.MinInt || b == math.MinInt {
+		return 0, ErrOverflow
+	}
+	if a < 0 {
+		a = -a
+	}
+	if b < 0 {
+		b = -b
+	}
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a, nil
+}
+
+func init() {
+	Register(Operator{Symbol: "+", Identity: 0, HasIdentity: true,
+		Apply: addInts,
+		ApplyBig: func(a, b *big.Int) (*big.Int, error) {
+			return new(big.Int).Add(a, b), nil
+		}})
+	Register(Operator{Symbol: "*", Identity: 1, HasIdentity: true,
+		Apply: mulInts,
+		ApplyBig: func(a, b *big.Int) (*big.Int, error) {
+			return new(big.Int).Mul(a, b), nil
+		}})
+	Register(Operator{Symbol: "-",
+		Apply: subInts,
+		ApplyBig: func(a, b *big.Int) (*big.Int, error) {
+			return new(big.Int).Sub(a, b), nil
+		}})
+	Register(Operator{Symbol: "/",
+		Apply: func(a, b int) (int, error) {
+			if b == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			if a == math.MinInt && b == -1 {
+				return 0, ErrOverflow
+			}
+			return a / b, nil
+		},
+		ApplyBig: func(a, b *big.Int) (*big.Int, error) {
+			if b.Sign() == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return new(big.Int).Quo(a, b), nil
+		}})
+	Register(Operator{Symbol: "%",
+		Apply: func(a, b int) (int, error) {
+			if b == 0 {
+				return 0, fmt.Errorf("modulo by zero")
+			}
+			if b == -1 {
+				return 0, nil
+			}
+			return a % b, nil
+		},
+		ApplyBig: func(a, b *big.Int) (*big.Int, error) {
+			if b.Sign() == 0 {
+				return nil, fmt.Errorf("modulo by zero")
+			}
+			return new(big.Int).Rem(a, b), nil
+		}})
+	Register(Operator{Symbol: "^",
+		Apply: func(a, b int) (int, error) {
+			if b < 0 {
+				return 0, fmt.Errorf("negative exponent %d", b)
+			}
+			return powInts(a, b)
+		},
+		ApplyBig: func(a, b *big.Int) (*big.Int, error) {
+			if b.Sign() < 0 {
+				return nil, fmt.Errorf("negative exponent %s", b)
+			}
+			return new(big.Int).Exp(a, b, nil), nil
+		}})
+	Register(Operator{Symbol: "min",
+		Apply: func(a, b int) (int, error) {
+			return min(a, b), nil
+		},
+		ApplyBig: func(a, b *big.Int) (*big.Int, error) {
+			if a.Cmp(b) <= 0 {
+				return a, nil
+			}
+			return b, nil
+		}})
+	Register(Operator{Symbol: "max",
+		Apply: func(a, b int) (int, error) {
+			return max(a, b), nil
+		},
+		ApplyBig: func(a, b *big.Int) (*big.Int, error) {
+			if a.Cmp(b) >= 0 {
+				return a, nil
+			}
+			return b, nil
+		}})
+	Register(Operator{Symbol: "gcd", Identity: 0, HasIdentity: true,
+		Apply: gcd,
+		ApplyBig: func(a, b *big.Int) (*big.Int, error) {
+			return new(big.Int).GCD(nil, nil, new(big.Int).Abs(a), new(big.Int).Abs(b)), nil
+		}})
+	Register(Operator{Symbol: "lcm", Identity: 1, HasIdentity: true,
+		Apply: func(a, b int) (int, error) {
+			if a == 0 || b == 0 {
+				return 0, nil
+			}
+			g, err := gcd(a, b)
+			if err != nil {
+				return 0, err
+			}
+			result, err := mulInts(a/g, b)
+			if err != nil || result == math.MinInt {
+				return 0, ErrOverflow
+			}
+			return max(result, -result), nil
+		},
+		ApplyBig: func(a, b *big.Int) (*big.Int, error) {
+			if a.Sign() == 0 || b.Sign() == 0 {
+				return big.NewInt(0), nil
+			}
+			g := new(big.Int).GCD(nil, nil, new(big.Int).Abs(a), new(big.Int).Abs(b))
+			result := new(big.Int).Mul(new(big.Int).Quo(a, g), b)
+			return result.Abs(result), nil
+		}})
+}